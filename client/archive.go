@@ -0,0 +1,411 @@
+package client
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/xackery/starteq/config"
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+)
+
+// BaseArchive describes a zip of the RoF2 client that can be downloaded over
+// http(s) instead of the torrent. Its files are extracted into everquest_rof2
+type BaseArchive struct {
+	URL   string        `yaml:"url"`
+	Size  int64         `yaml:"size"`
+	Md5   string        `yaml:"md5"`
+	Parts []ArchivePart `yaml:"parts"` // used instead of url when the zip is split, in order
+}
+
+// ArchivePart is a piece of a split BaseArchive
+type ArchivePart struct {
+	URL  string `yaml:"url"`
+	Size int64  `yaml:"size"`
+	Md5  string `yaml:"md5"`
+}
+
+// Validate returns an error if the archive appears off
+func (a *BaseArchive) Validate() error {
+	if a.URL == "" && len(a.Parts) == 0 {
+		return fmt.Errorf("needs a url or parts")
+	}
+	if a.URL != "" {
+		err := config.ValidateURL(a.URL)
+		if err != nil {
+			return err
+		}
+	}
+	for i, part := range a.Parts {
+		err := config.ValidateURL(part.URL)
+		if err != nil {
+			return fmt.Errorf("part %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// parts returns the archive as a list of parts, a single part if it is not split
+func (a *BaseArchive) parts() []ArchivePart {
+	if len(a.Parts) > 0 {
+		return a.Parts
+	}
+	return []ArchivePart{{URL: a.URL, Size: a.Size, Md5: a.Md5}}
+}
+
+// size returns the total size of the archive, 0 if unknown
+func (a *BaseArchive) size() int64 {
+	if a.Size > 0 || len(a.Parts) == 0 {
+		return a.Size
+	}
+	total := int64(0)
+	for _, part := range a.Parts {
+		if part.Size <= 0 {
+			return 0
+		}
+		total += part.Size
+	}
+	return total
+}
+
+// baseArchive returns the archive set in the ini, else the one from the
+// server's launcher.yml, nil if neither has one
+func (c *Client) baseArchive() *BaseArchive {
+	if len(c.cfg.ArchiveURLs) == 1 {
+		return &BaseArchive{URL: c.cfg.ArchiveURLs[0], Size: int64(c.cfg.ArchiveSize), Md5: c.cfg.ArchiveMd5}
+	}
+	if len(c.cfg.ArchiveURLs) > 1 {
+		// archive_size and archive_md5 are checked against the joined parts
+		a := &BaseArchive{Size: int64(c.cfg.ArchiveSize), Md5: c.cfg.ArchiveMd5}
+		for _, url := range c.cfg.ArchiveURLs {
+			a.Parts = append(a.Parts, ArchivePart{URL: url})
+		}
+		return a
+	}
+	settings := c.serverSettings()
+	if settings == nil {
+		return nil
+	}
+	return settings.BaseArchive
+}
+
+// downloadBase downloads the RoF2 client into everquest_rof2 from the
+// configured source. auto uses the torrent and falls back to the http archive
+func (c *Client) downloadBase(ctx context.Context) error {
+	archive := c.baseArchive()
+	switch c.cfg.BaseSource {
+	case "http":
+		if archive == nil {
+			return fmt.Errorf("download.source is http but no archive is configured")
+		}
+		return c.downloadArchive(ctx, archive)
+	case "torrent":
+		return c.downloadTorrent(ctx)
+	}
+
+	err := c.downloadTorrent(ctx)
+	if err == nil || archive == nil || ctx.Err() != nil {
+		return err
+	}
+	slog.Print("Torrent failed, downloading over http instead: %s", err)
+	return c.downloadArchive(ctx, archive)
+}
+
+// downloadTorrent asks to use the torrent if it was not allowed before and downloads it
+func (c *Client) downloadTorrent(ctx context.Context) error {
+	if !c.cfg.IsTorrentOK {
+		if !gui.MessageBoxYesNo("EverQuest not found", "EverQuest was not found in the current directory.\nUse torrent software to download it?") {
+			return fmt.Errorf("cancelled torrent download. Download EQ manually and place in current directory")
+		}
+		c.cfg.IsTorrentOK = true
+		err := c.saveConfig()
+		if err != nil {
+			return fmt.Errorf("save config: %w", err)
+		}
+	}
+	err := c.Torrent(ctx)
+	if err != nil {
+		return fmt.Errorf("torrent: %w", err)
+	}
+	return nil
+}
+
+// downloadArchive downloads every part of a, resuming partial parts left by
+// an earlier launch, verifies them and extracts the zip into everquest_rof2.
+// The zip is extracted to a temp folder first, so everquest_rof2 only exists
+// once every file is in place
+func (c *Client) downloadArchive(ctx context.Context, a *BaseArchive) error {
+	parts := a.parts()
+	total := a.size()
+	if total > 0 {
+		// the parts and the extracted files are on disk at the same time
+		err := c.checkSpace(c.currentPath, uint64(total)*2)
+		if err != nil {
+			return err
+		}
+	}
+
+	client := &http.Client{}
+	done := int64(0)
+	paths := []string{}
+	for i, part := range parts {
+		path := fmt.Sprintf(".%s-base.part%d", c.baseName, i+1)
+		paths = append(paths, path)
+		slog.Print("Downloading EverQuest part %d of %d from %s", i+1, len(parts), part.URL)
+		err := downloadPart(ctx, client, part, path, func(n int64) {
+			if total > 0 {
+				gui.SetProgress(int(float64(done+n) / float64(total) * 100))
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("part %d: %w", i+1, err)
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", path, err)
+		}
+		done += fi.Size()
+	}
+
+	r, err := openParts(paths)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if len(parts) > 1 {
+		err = verifyJoined(a, r)
+		if err != nil {
+			r.Close()
+			removeParts(paths)
+			return fmt.Errorf("%w, removed the parts so the next try starts over", err)
+		}
+	}
+
+	zr, err := zip.NewReader(r, r.size)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	tmpDir := "." + c.baseName + "-base.extract"
+	err = os.RemoveAll(tmpDir)
+	if err != nil {
+		return fmt.Errorf("remove %s: %w", tmpDir, err)
+	}
+	slog.Print("Extracting EverQuest to everquest_rof2...")
+	err = unpackReader(ctx, zr, tmpDir, nil)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return fmt.Errorf("extract: %w", err)
+	}
+	r.Close()
+
+	// anything already in everquest_rof2 is an incomplete download, eqgame.exe is missing
+	err = os.RemoveAll("everquest_rof2")
+	if err != nil {
+		return fmt.Errorf("remove incomplete everquest_rof2: %w", err)
+	}
+	err = os.Rename(tmpDir, "everquest_rof2")
+	if err != nil {
+		return fmt.Errorf("rename %s: %w", tmpDir, err)
+	}
+	removeParts(paths)
+	return nil
+}
+
+// verifyJoined checks the joined parts in r against the size and md5 of the whole archive
+func verifyJoined(a *BaseArchive, r *partsReader) error {
+	if a.Size > 0 && r.size != a.Size {
+		return fmt.Errorf("archive is %d bytes, expected %d", r.size, a.Size)
+	}
+	if a.Md5 == "" {
+		return nil
+	}
+	h := md5.New()
+	_, err := io.Copy(h, io.NewSectionReader(r, 0, r.size))
+	if err != nil {
+		return fmt.Errorf("checksum archive: %w", err)
+	}
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+	if !strings.EqualFold(hash, a.Md5) {
+		return fmt.Errorf("archive md5 %s does not match %s", hash, a.Md5)
+	}
+	return nil
+}
+
+// removeParts deletes the downloaded archive parts
+func removeParts(paths []string) {
+	for _, path := range paths {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			slog.Print("Failed to remove %s: %s", path, err)
+		}
+	}
+}
+
+// downloadPart downloads part to path, continuing a partial file with a range
+// request when the server supports it, then checks its size and md5
+func downloadPart(ctx context.Context, client *http.Client, part ArchivePart, path string, progress func(n int64)) error {
+	offset := int64(0)
+	fi, err := os.Stat(path)
+	if err == nil {
+		offset = fi.Size()
+	}
+	if part.Size > 0 && offset > part.Size {
+		offset = 0
+	}
+
+	if part.Size == 0 || offset < part.Size {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, part.URL, nil)
+		if err != nil {
+			return fmt.Errorf("request: %w", err)
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("download %s: %w", part.URL, err)
+		}
+		defer resp.Body.Close()
+
+		flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			slog.Print("Resuming at %s", generateSize(int(offset)))
+		case resp.StatusCode == http.StatusOK:
+			offset = 0
+			flag |= os.O_TRUNC
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && part.Size == 0:
+			// the partial file from an earlier run is already complete
+			resp.Body.Close()
+			progress(offset)
+			return verifyPart(part, path)
+		default:
+			return fmt.Errorf("download %s responded %d", part.URL, resp.StatusCode)
+		}
+
+		w, err := os.OpenFile(path, flag, 0644)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", path, err)
+		}
+		_, err = io.Copy(w, &progressReader{r: resp.Body, n: offset, fn: progress})
+		closeErr := w.Close()
+		if err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+		if closeErr != nil {
+			return fmt.Errorf("close %s: %w", path, closeErr)
+		}
+	}
+	return verifyPart(part, path)
+}
+
+// verifyPart checks a downloaded part against its size and md5, removing it if it does not match
+func verifyPart(part ArchivePart, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if part.Size > 0 && fi.Size() != part.Size {
+		return fmt.Errorf("%s is %d bytes, expected %d", path, fi.Size(), part.Size)
+	}
+	if part.Md5 == "" {
+		return nil
+	}
+	hash, err := md5Checksum(path)
+	if err != nil {
+		return fmt.Errorf("checksum %s: %w", path, err)
+	}
+	if !strings.EqualFold(hash, part.Md5) {
+		os.Remove(path)
+		return fmt.Errorf("md5 %s does not match %s, removed it so the next try starts over", hash, part.Md5)
+	}
+	return nil
+}
+
+// progressReader calls fn with the bytes read so far, starting at n
+type progressReader struct {
+	r  io.Reader
+	n  int64
+	fn func(n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	p.fn(p.n)
+	return n, err
+}
+
+// partsReader reads a list of files as if they were one
+type partsReader struct {
+	files []*os.File
+	sizes []int64
+	size  int64
+}
+
+func openParts(paths []string) (*partsReader, error) {
+	r := &partsReader{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			r.Close()
+			return nil, fmt.Errorf("stat %s: %w", path, err)
+		}
+		r.files = append(r.files, f)
+		r.sizes = append(r.sizes, fi.Size())
+		r.size += fi.Size()
+	}
+	return r, nil
+}
+
+// ReadAt implements io.ReaderAt across the parts
+func (r *partsReader) ReadAt(b []byte, off int64) (int, error) {
+	total := 0
+	for i, f := range r.files {
+		size := r.sizes[i]
+		if off >= size {
+			off -= size
+			continue
+		}
+		want := b
+		if int64(len(want)) > size-off {
+			want = want[:size-off]
+		}
+		n, err := f.ReadAt(want, off)
+		total += n
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+		if n < len(want) {
+			return total, io.ErrUnexpectedEOF
+		}
+		b = b[n:]
+		off = 0
+		if len(b) == 0 {
+			return total, nil
+		}
+	}
+	return total, io.EOF
+}
+
+// Close closes every part
+func (r *partsReader) Close() error {
+	for _, f := range r.files {
+		f.Close()
+	}
+	r.files = nil
+	return nil
+}
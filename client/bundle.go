@@ -0,0 +1,255 @@
+package client
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/xackery/starteq/slog"
+)
+
+// installBundles downloads and extracts every bundle whose version changed
+// since it was last installed, returning pending without the downloads the
+// bundles provided. A filelist without bundles falls back to maps.zip at the
+// patcher url when maps are missing or stale
+func (c *Client) installBundles(fileList *FileList, pending []FileEntry) ([]FileEntry, error) {
+	c.removeOldMapsZip(fileList)
+
+	bundles := fileList.Bundles
+	if len(bundles) == 0 {
+		for _, entry := range pending {
+			if strings.HasPrefix(strings.ToLower(entry.Name), "maps/") {
+				bundles = []Bundle{{Name: "maps.zip", Prefix: "maps/", isLegacy: true}}
+				break
+			}
+		}
+	}
+	if len(bundles) == 0 {
+		return pending, nil
+	}
+
+	state, err := c.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	installed := []Bundle{}
+	for _, b := range bundles {
+		if !b.isLegacy && b.stamp() != "" && state.Bundles[b.Name] == b.stamp() {
+			slog.Debug("Bundle up to date", "bundle", b.Name, "version", b.Version)
+			continue
+		}
+		select {
+		case <-c.patchCtx.Done():
+			return nil, fmt.Errorf("patch cancelled")
+		default:
+		}
+		err = c.installBundle(b, fileList)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Name, err)
+		}
+		installed = append(installed, b)
+		if !b.isLegacy {
+			err = c.updateState(func(state *State) {
+				if state.Bundles == nil {
+					state.Bundles = map[string]string{}
+				}
+				state.Bundles[b.Name] = b.stamp()
+			})
+			if err != nil {
+				slog.Print("Failed to record bundle %s: %s", b.Name, err)
+			}
+		}
+	}
+	if len(installed) == 0 {
+		return pending, nil
+	}
+
+	// anything the bundles did not bring up to date is still downloaded on its own
+	out := []FileEntry{}
+	for _, entry := range pending {
+		if !isBundled(installed, entry.Name) {
+			out = append(out, entry)
+			continue
+		}
+		name, err := c.safePath(entry.Name)
+		if err != nil {
+			continue
+		}
+		hash, err := md5Checksum(name)
+		if err == nil && strings.EqualFold(hash, entry.Md5) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// stamp identifies what was extracted for b in the state file, its version or
+// else its md5. Without either the bundle is extracted on every patch
+func (b Bundle) stamp() string {
+	if b.Version != "" {
+		return b.Version
+	}
+	if b.Md5 != "" {
+		return "md5:" + strings.ToLower(b.Md5)
+	}
+	return ""
+}
+
+// isBundled returns true if name is provided by one of bundles
+func isBundled(bundles []Bundle, name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "\\", "/"))
+	for _, b := range bundles {
+		prefix := strings.ToLower(strings.ReplaceAll(b.Prefix, "\\", "/"))
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// installBundle downloads b to a temp file, verifies it, extracts it and
+// removes the temp file
+func (c *Client) installBundle(b Bundle, fileList *FileList) error {
+	dir := "."
+	if b.Dir != "" {
+		var err error
+		dir, err = c.safePath(b.Dir)
+		if err != nil {
+			return fmt.Errorf("dir: %w", err)
+		}
+	}
+
+	extracted := uint64(0)
+	for _, entry := range fileList.Downloads {
+		if isBundled([]Bundle{b}, entry.Name) {
+			extracted += uint64(entry.Size)
+		}
+	}
+	if extracted == 0 {
+		extracted = uint64(b.Size)
+	}
+	err := c.checkSpace(os.TempDir(), uint64(b.Size))
+	if err != nil {
+		return err
+	}
+	err = c.checkSpace(c.currentPath, extracted)
+	if err != nil {
+		return err
+	}
+
+	if b.Version == "" {
+		slog.Print("Downloading %s...", b.Name)
+	} else {
+		slog.Print("Downloading %s version %s...", b.Name, b.Version)
+	}
+	var resp *http.Response
+	if b.isLegacy {
+		url := fmt.Sprintf("%s/%s", c.patcherUrl, b.Name)
+		resp, err = c.downloadClient.Get(url)
+		if err != nil {
+			return fmt.Errorf("download %s: %w", url, err)
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+		}
+	} else {
+		resp, err = c.getPatchFile(c.downloadClient, b.Name)
+		if err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	w, err := os.CreateTemp("", c.baseName+"-*-"+path.Base(b.Name))
+	if err != nil {
+		return fmt.Errorf("create temp: %w", err)
+	}
+	tmpPath := w.Name()
+	defer os.Remove(tmpPath)
+
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(w, h), resp.Body)
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("write %s: %w", tmpPath, err)
+	}
+	if b.Size > 0 && n != int64(b.Size) {
+		return fmt.Errorf("downloaded %d bytes, expected %d", n, b.Size)
+	}
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+	if b.Md5 != "" && !strings.EqualFold(hash, b.Md5) {
+		return fmt.Errorf("md5 %s does not match %s", hash, b.Md5)
+	}
+
+	kept := 0
+	keep := c.keepExisting(fileList)
+	err = unpack(c.patchCtx, tmpPath, dir, func(path string) bool {
+		if !keep(path) {
+			return false
+		}
+		kept++
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("unzip: %w", err)
+	}
+	slog.Print("%s extracted (%s)", b.Name, generateSize(int(n)))
+	if kept > 0 {
+		slog.Print("Kept %d protected or create only files from %s", kept, b.Name)
+	}
+	return nil
+}
+
+// keepExisting returns a filter for unpack that leaves protected and create
+// only files alone once they exist, like the per file downloads do
+func (c *Client) keepExisting(fileList *FileList) func(path string) bool {
+	createOnly := map[string]bool{}
+	for _, entry := range fileList.Downloads {
+		if !entry.CreateOnly {
+			continue
+		}
+		name, err := c.safePath(entry.Name)
+		if err != nil {
+			continue
+		}
+		createOnly[strings.ToLower(manifestKey(name))] = true
+	}
+	return func(path string) bool {
+		rel, err := c.gameRelPath(path)
+		if err != nil {
+			return false
+		}
+		if !c.isProtected(rel) && !createOnly[strings.ToLower(manifestKey(rel))] {
+			return false
+		}
+		_, err = os.Stat(path)
+		return err == nil
+	}
+}
+
+// removeOldMapsZip deletes the maps.zip older launchers left in the game folder
+func (c *Client) removeOldMapsZip(fileList *FileList) {
+	for _, entry := range fileList.Downloads {
+		if strings.EqualFold(entry.Name, "maps.zip") {
+			return
+		}
+	}
+	if c.isProtected("maps.zip") {
+		return
+	}
+	err := os.Remove("maps.zip")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Print("Failed to remove old maps.zip: %s", err)
+		}
+		return
+	}
+	slog.Print("Removed old maps.zip")
+}
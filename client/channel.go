@@ -0,0 +1,43 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xackery/starteq/slog"
+)
+
+// channelPrefixes returns the patcher urls checked for the configured release
+// channel, most specific first. Beta falls back to stable, and stable falls
+// back to the patcher root so servers without channel folders keep working
+func (c *Client) channelPrefixes() []string {
+	prefixes := []string{}
+	if c.cfg.Channel != "" && c.cfg.Channel != "stable" {
+		prefixes = append(prefixes, fmt.Sprintf("%s/%s", c.patcherUrl, c.cfg.Channel))
+	}
+	return append(prefixes, fmt.Sprintf("%s/stable", c.patcherUrl), c.patcherUrl)
+}
+
+// getChannelFile downloads name from the first channel prefix that has it,
+// returning the response and the prefix it was found under
+func (c *Client) getChannelFile(name string) (*http.Response, string, error) {
+	var lastErr error
+	for _, prefix := range c.channelPrefixes() {
+		url := fmt.Sprintf("%s/%s", prefix, name)
+		slog.Debug("Checking channel", "url", url)
+		resp, err := c.httpClient.Get(url)
+		if err != nil {
+			lastErr = fmt.Errorf("download %s: %w", url, err)
+			continue
+		}
+		if resp.StatusCode == 200 {
+			return resp, prefix, nil
+		}
+		resp.Body.Close()
+		lastErr = fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+		if resp.StatusCode != 404 {
+			return nil, "", lastErr
+		}
+	}
+	return nil, "", lastErr
+}
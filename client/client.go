@@ -12,38 +12,52 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xackery/starteq/config"
 	"github.com/xackery/starteq/gui"
 	"github.com/xackery/starteq/slog"
+	"github.com/xackery/starteq/torrent"
 	"gopkg.in/yaml.v3"
-
-	"github.com/fynelabs/selfupdate"
 )
 
-var isMapsDownloaded bool
-
 // Client wraps the entire UI
 type Client struct {
-	ctx           context.Context
-	cancel        context.CancelFunc
-	baseName      string
-	patcherUrl    string
-	currentPath   string
-	clientVersion string
-	isPatchEvent  bool // true when a file was downloaded/a patch occured
-	patchSummary  string
-	cfg           *config.Config
-	cacheFileList *FileList
-	version       string
-	httpClient    *http.Client
-	patchCtx      context.Context
-	patchCancel   context.CancelFunc
+	ctx              context.Context
+	cancel           context.CancelFunc
+	baseName         string
+	patcherUrl       string
+	currentPath      string
+	clientVersion    string
+	isPatchEvent     bool // true when a file was downloaded/a patch occured
+	patchSummary     string
+	cfg              *config.Config
+	cacheFileList    *FileList
+	fileListMu       sync.Mutex // guards replacing cacheFileList, which diagnostics reads from the gui
+	version          string
+	httpClient       *http.Client
+	downloadClient   *http.Client // no overall timeout, for files too big to fetch within httpClient's
+	patchMu          sync.Mutex   // held while a patch, repair or import runs
+	patchCtx         context.Context
+	patchCancel      context.CancelFunc
+	launcherSettings *LauncherSettings // nil if the server does not publish launcher.yml
+	settingsMu       sync.Mutex        // guards launcherSettings and the server values merged into cfg, read while seeding
+	mirrors          []string          // extra download prefixes tried after the filelist's
+	maxDownloads     int               // files downloaded at once
+	gameMu           sync.Mutex
+	games            map[string]*gameProcess // running eqgame.exe by account, nil while starting
+	gameSeq          int                     // number of eqgame.exe launched, used to name them in the log
+	gameWg           sync.WaitGroup          // supervised eqgame.exe that haven't exited yet
+	stateMu          sync.Mutex              // guards the state file, written by the patch, torrent progress and seeding
+	seedMu           sync.Mutex
+	seedDone         chan struct{} // closed when seeding stops, nil if it never started
+	torrenter        torrent.Torrenter
+	torrentData      []byte // rof2.torrent
 }
 
 // New creates a new client
-func New(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, version string, patcherUrl string) (*Client, error) {
+func New(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, version string, patcherUrl string, opts ...Option) (*Client, error) {
 	var err error
 	c := &Client{
 		ctx:           ctx,
@@ -52,9 +66,16 @@ func New(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, ver
 		clientVersion: "rof",
 		patcherUrl:    patcherUrl,
 		version:       version,
+		games:         make(map[string]*gameProcess),
 		httpClient: &http.Client{
 			Timeout: 3 * time.Second,
 		},
+		downloadClient: &http.Client{},
+		torrenter:      &torrent.Torrent{},
+		torrentData:    torrentContent,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	exeName, err := os.Executable()
 	if err != nil {
@@ -76,21 +97,80 @@ func New(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, ver
 	}
 
 	gui.SubscribePatchButton(func() {
-		err := c.Patch()
+		go func() {
+			err := c.Patch()
+			if err != nil {
+				slog.Print("Failed to patch: %s", err)
+			}
+		}()
+	})
+	gui.SubscribePlayButton(func() {
+		go func() {
+			err := c.Play()
+			if err != nil {
+				slog.Print("Failed to play: %s", err)
+			}
+		}()
+	})
+	gui.SubscribeMultibox(func() {
+		accounts, ok := gui.SelectAccounts("Multibox", c.knownAccounts(), c.defaultAccounts())
+		if !ok || len(accounts) == 0 {
+			return
+		}
+		go func() {
+			err := c.PlayAccounts(accounts)
+			if err != nil {
+				slog.Print("Failed to play: %s", err)
+			}
+		}()
+	})
+	gui.SubscribeRepair(func() {
+		go func() {
+			err := c.Repair()
+			if err != nil {
+				slog.Print("Failed to repair: %s", err)
+			}
+		}()
+	})
+	gui.SubscribeImportInstall(c.selectImport)
+	gui.SubscribeInstallVersion(func() {
+		version, ok := c.selectVersion()
+		if !ok {
+			return
+		}
+		err := c.InstallVersion(version)
 		if err != nil {
-			slog.Print("Failed to patch: %s", err)
+			slog.Print("Failed to install version %s: %s", version, err)
 		}
 	})
-	gui.SubscribePlayButton(func() { c.Play() })
+	gui.SubscribeExportDiagnostics(func() {
+		// hashing and listing the game folder takes a while, keep the window responsive
+		go func() {
+			path, err := c.ExportDiagnostics()
+			if err != nil {
+				slog.Print("Failed to export diagnostics: %s", err)
+				gui.MessageBox("Error", "Failed to export diagnostics: "+err.Error(), true)
+				return
+			}
+			gui.MessageBox("Diagnostics", "Diagnostics saved to "+filepath.Join(c.currentPath, path)+"\nPlease attach this file when reporting a problem.", false)
+		}()
+	})
 	gui.SubscribeAutoPatch(func() {
 		c.cfg.IsAutoPatch = gui.IsAutoPatch()
-		c.cfg.Save()
+		err := c.saveConfig()
+		if err != nil {
+			slog.Print("Failed to save %s.ini: %s", c.baseName, err)
+		}
 	})
 	gui.SubscribeAutoPlay(func() {
 		c.cfg.IsAutoPlay = gui.IsAutoPlay()
-		c.cfg.Save()
+		err := c.saveConfig()
+		if err != nil {
+			slog.Print("Failed to save %s.ini: %s", c.baseName, err)
+		}
 	})
 
+	c.startSeeding()
 	return c, nil
 }
 
@@ -108,6 +188,10 @@ func (c *Client) AutoPlay() error {
 		}
 	}
 
+	if c.ctx.Err() != nil {
+		return fmt.Errorf("launcher closing")
+	}
+
 	if c.cfg.IsAutoPlay {
 		fmt.Println("Autoplay is enabled, playing...")
 		if c.isPatchEvent {
@@ -141,35 +225,7 @@ func (c *Client) Play() error {
 	if username == "" {
 		username = "x"
 	}
-	cmd := c.createCommand(true, fmt.Sprintf("%s/eqgame.exe", c.currentPath), "patchme", "/login:"+username)
-	cmd.Dir = c.currentPath
-	err = cmd.Start()
-	if err != nil {
-		return fmt.Errorf("start eqgame.exe: %w", err)
-	}
-
-	time.Sleep(1000 * time.Millisecond)
-	isStarted := false
-	// poll for process to be started
-	for i := 0; i < 10; i++ {
-		if cmd.Process == nil {
-			time.Sleep(500 * time.Millisecond)
-			continue
-		}
-		// check if process is running
-		_, err := os.FindProcess(cmd.Process.Pid)
-		if err != nil {
-			time.Sleep(500 * time.Millisecond)
-			continue
-		}
-		isStarted = true
-		slog.Printf("EverQuest started with process ID %d\n", cmd.Process.Pid)
-		break
-	}
-	if !isStarted {
-		return fmt.Errorf("failed to start eqgame.exe")
-	}
-	return nil
+	return c.launch(username)
 }
 
 func (c *Client) PrePatch() error {
@@ -197,19 +253,9 @@ func (c *Client) PrePatch() error {
 			return nil
 		}
 
-		if !c.cfg.IsTorrentOK {
-			if !gui.MessageBoxYesNo("EverQuest not found", "EverQuest was not found in the current directory.\nUse torrent software to download it?") {
-				return fmt.Errorf("cancelled torrent download. Download EQ manually and place in current directory")
-			}
-			c.cfg.IsTorrentOK = true
-			err = c.cfg.Save()
-			if err != nil {
-				return fmt.Errorf("save config: %w", err)
-			}
-		}
-		err = c.Torrent(c.patchCtx)
+		err = c.downloadBase(c.patchCtx)
 		if err != nil {
-			return fmt.Errorf("torrent: %w", err)
+			return fmt.Errorf("download everquest: %w", err)
 		}
 		err = c.CopyBackup("everquest_rof2")
 		if err != nil {
@@ -220,12 +266,18 @@ func (c *Client) PrePatch() error {
 }
 
 func (c *Client) Patch() error {
-	var err error
-	defer slog.Dump(c.baseName + ".txt")
-	if c.patchCtx != nil && c.patchCtx.Err() == nil {
+	if !c.patchMu.TryLock() {
 		slog.Print("Patch already in progress")
 		return fmt.Errorf("patch already in progress")
 	}
+	defer c.patchMu.Unlock()
+	return c.runPatch()
+}
+
+// runPatch patches the game folder, the caller must hold patchMu
+func (c *Client) runPatch() error {
+	var err error
+	defer slog.Dump(c.baseName + ".txt")
 
 	gui.SetPatchText("Cancel")
 	defer gui.SetPatchText("Patch")
@@ -277,6 +329,17 @@ func (c *Client) selfUpdateAndPatch() error {
 		return fmt.Errorf("patch cancelled")
 	default:
 	}
+	settings, err := c.fetchLauncherSettings()
+	if err != nil {
+		slog.Print("Failed fetch launcher settings, skipping: %s", err)
+	}
+	err = c.applyLauncherSettings(settings)
+	if err != nil {
+		return fmt.Errorf("launcher settings: %w", err)
+	}
+	if c.isLauncherOutdated() {
+		return c.forceSelfUpdate()
+	}
 	err = c.fetchFileList()
 	if err != nil {
 		slog.Print("Failed fetch file list, skipping: %s", err)
@@ -296,7 +359,7 @@ func (c *Client) selfUpdateAndPatch() error {
 		return fmt.Errorf("patch cancelled")
 	default:
 	}
-	err = c.selfUpdate()
+	_, err = c.selfUpdate()
 	if err != nil {
 		slog.Print("Failed self update, skipping: %s", err)
 	}
@@ -305,21 +368,24 @@ func (c *Client) selfUpdateAndPatch() error {
 }
 
 func (c *Client) fetchFileList() error {
-	client := c.httpClient
-	url := fmt.Sprintf("%s/filelist_%s.yml", c.patcherUrl, c.clientVersion)
-	slog.Print("Downloading %s", url)
-	resp, err := client.Get(url)
+	if c.cfg.PinVersion != "" {
+		return c.fetchPinnedFileList()
+	}
+	name := fmt.Sprintf("filelist_%s.yml", c.clientVersion)
+	slog.Print("Downloading %s for the %s channel", name, c.cfg.Channel)
+	resp, _, err := c.getChannelFile(name)
 	if err != nil {
-		url := fmt.Sprintf("%s/%s/filelist_%s.yml", c.patcherUrl, c.clientVersion, c.clientVersion)
+		url := fmt.Sprintf("%s/%s/%s", c.patcherUrl, c.clientVersion, name)
 		slog.Print("Downloading legacy %s", url)
-		resp, err = client.Get(url)
+		resp, err = c.httpClient.Get(url)
 		if err != nil {
 			return fmt.Errorf("download %s: %w", url, err)
 		}
-	}
-	if resp.StatusCode != 200 {
-		c.cacheFileList = &FileList{}
-		return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			c.setFileList(&FileList{})
+			return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+		}
 	}
 
 	defer resp.Body.Close()
@@ -330,124 +396,22 @@ func (c *Client) fetchFileList() error {
 		return fmt.Errorf("decode filelist: %w", err)
 	}
 	//slog.Print("patch version is", fileList.Version, "and we are version", c.cfg.ClientVersion)
-	c.cacheFileList = fileList
+	c.setFileList(fileList)
 	return nil
 }
 
-func (c *Client) selfUpdate() error {
-	client := c.httpClient
-
-	exeName, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("executable: %w", err)
-	}
-
-	baseName := c.baseName
-
-	err = os.Remove(baseName + ".bat")
-	if err != nil {
-		if !os.IsNotExist(err) {
-			slog.Print("Failed to remove %s.bat: %s", baseName, err)
-		}
-	} else {
-		slog.Print("Removed %s.bat", baseName)
-	}
-
-	err = os.Remove("." + baseName + ".exe.old")
-	if err != nil {
-		if !os.IsNotExist(err) {
-			slog.Print("Failed to remove .%s.exe.old: %s", baseName, err)
-		}
-	} else {
-		slog.Print("Removed .%s.exe.old", baseName)
-	}
-
-	myHash, err := md5Checksum(exeName)
-	if err != nil {
-		return fmt.Errorf("checksum: %w", err)
-	}
-	url := fmt.Sprintf("%s/starteq-hash.txt", c.patcherUrl)
-	slog.Print("Checking for self update at %s", url)
-	resp, err := client.Get(url)
-	if err != nil {
-		return fmt.Errorf("download %s: %w", url, err)
-	}
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if err != nil {
-		return fmt.Errorf("read %s: %w", url, err)
-	}
-
-	remoteHash := strings.TrimSpace(string(data))
-
-	if remoteHash == "Not Found" {
-		slog.Print("Remote site down, ignoring self update")
-		return nil
-	}
-
-	if strings.EqualFold(myHash, remoteHash) {
-		slog.Print("Self update not needed")
-		return nil
-	}
-
-	slog.Print("Updating %s... %s vs %s", c.baseName, myHash, remoteHash)
+// setFileList replaces the cached filelist
+func (c *Client) setFileList(fileList *FileList) {
+	c.fileListMu.Lock()
+	c.cacheFileList = fileList
+	c.fileListMu.Unlock()
+}
 
-	url = fmt.Sprintf("%s/%s.exe", c.patcherUrl, c.baseName)
-	slog.Print("Downloading %s at %s", c.baseName, url)
-	resp, err = client.Get(url)
-	if err != nil {
-		return fmt.Errorf("get: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
-	}
-	slog.Print("Applying update (will be used next launch)")
-	err = selfupdate.Apply(resp.Body, selfupdate.Options{})
-	if err != nil {
-		return fmt.Errorf("apply: %w", err)
-	}
-
-	//isErrored := false
-
-	// slog.Print("Creating %s.bat", c.baseName)
-	// err = os.WriteFile(fmt.Sprintf("%s.bat", c.baseName), []byte(fmt.Sprintf("timeout 1\n%s.exe", c.baseName)), os.ModePerm)
-	// if err != nil {
-	// 	fmt.Printf("Failed to write %s.bat: %s\n", c.baseName, err)
-	// 	isErrored = true
-	// }
-
-	// slog.Print("Writing log")
-	// err = os.WriteFile(fmt.Sprintf("%s.txt", c.baseName), []byte(c.cacheLog), os.ModePerm)
-	// if err != nil {
-	// 	fmt.Println("Failed to write log:", err)
-	// 	isErrored = true
-	// }
-
-	// cmd := c.createCommand(false, fmt.Sprintf("%s/%s.bat", c.currentPath, c.baseName))
-	// cmd.Dir = c.currentPath
-	// err = cmd.Start()
-	// if err != nil {
-	// 	fmt.Printf("Failed to start %s.bat: %s\n", c.baseName, err)
-	// 	isErrored = true
-	// }
-
-	// if isErrored && runtime.GOOS == "windows" {
-	// 	fmt.Printf("There was an error while self updating %s. Review above or %s.txt to see why.\n", c.baseName, c.baseName)
-	// 	fmt.Println("Automatically exiting in 10 seconds...")
-	// 	time.Sleep(10 * time.Second)
-	// 	os.Exit(1)
-	// }
-
-	// slog.Print("Successfully updated. Restarting %s and starting EverQuest...", c.baseName)
-	// time.Sleep(1 * time.Second)
-	// os.Exit(0)
-	return nil
+// cachedFileList returns the filelist the last patch downloaded, nil if none
+func (c *Client) cachedFileList() *FileList {
+	c.fileListMu.Lock()
+	defer c.fileListMu.Unlock()
+	return c.cacheFileList
 }
 
 func (c *Client) patch() error {
@@ -484,41 +448,42 @@ func (c *Client) patch() error {
 	ratio := float64(totalSize / 100)
 	gui.SetProgress(0)
 
+	pending := []FileEntry{}
 	for _, entry := range fileList.Downloads {
 		select {
 		case <-c.patchCtx.Done():
 			return fmt.Errorf("patch cancelled")
 		default:
 		}
-		if strings.Contains(entry.Name, "..") {
-			slog.Print("Skipping %s, has .. inside it", entry.Name)
+		name, err := c.safePath(entry.Name)
+		if err != nil {
+			slog.Print("Skipping %s: %s", entry.Name, err)
 			continue
 		}
 
-		if strings.Contains(entry.Name, "/") {
-			newPath := strings.TrimSuffix(entry.Name, filepath.Base(entry.Name))
-			err = os.MkdirAll(newPath, os.ModePerm)
+		if filepath.Dir(name) != "." {
+			err = os.MkdirAll(filepath.Dir(name), os.ModePerm)
 			if err != nil {
-				return fmt.Errorf("mkdir %s: %w", newPath, err)
+				return fmt.Errorf("mkdir %s: %w", filepath.Dir(name), err)
 			}
 		}
-		_, err := os.Stat(entry.Name)
+		_, err = os.Stat(name)
 		if err != nil {
 			if os.IsNotExist(err) {
-				err = c.downloadPatchFile(entry)
-				if err != nil {
-					return fmt.Errorf("download new file: %w", err)
-				}
-				totalDownloaded += int64(entry.Size)
-				progressSize += int64(entry.Size)
-				gui.SetProgress(int(ratio * float64(progressSize)))
-				c.isPatchEvent = true
+				pending = append(pending, entry)
 				continue
 			}
-			return fmt.Errorf("stat %s: %w", entry.Name, err)
+			return fmt.Errorf("stat %s: %w", name, err)
 		}
 
-		hash, err := md5Checksum(entry.Name)
+		if entry.CreateOnly || c.isProtected(name) {
+			slog.Print("%s skipped (protected)", entry.Name)
+			progressSize += int64(entry.Size)
+			gui.SetProgress(int(ratio * float64(progressSize)))
+			continue
+		}
+
+		hash, err := md5Checksum(name)
 		if err != nil {
 			return fmt.Errorf("md5checksum: %w", err)
 		}
@@ -529,47 +494,58 @@ func (c *Client) patch() error {
 			gui.SetProgress(int(ratio * float64(progressSize)))
 			continue
 		}
+		pending = append(pending, entry)
+	}
 
-		err = c.downloadPatchFile(entry)
-		if err != nil {
-			return fmt.Errorf("download new file: %w", err)
-		}
+	pending, err = c.installBundles(fileList, pending)
+	if err != nil {
+		return fmt.Errorf("bundles: %w", err)
+	}
+
+	err = c.checkPatchSpace(pending)
+	if err != nil {
+		return err
+	}
+
+	progressMu := sync.Mutex{}
+	downloaded := []FileEntry{}
+	err = c.downloadAll(pending, func(entry FileEntry) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
 		progressSize += int64(entry.Size)
 		totalDownloaded += int64(entry.Size)
 		gui.SetProgress(int(ratio * float64(progressSize)))
 		c.isPatchEvent = true
+		downloaded = append(downloaded, entry)
+	})
+	recordErr := c.recordInstalled(downloaded)
+	if recordErr != nil {
+		slog.Print("Failed to record installed files: %s", recordErr)
+	}
+	if err != nil {
+		return fmt.Errorf("download new file: %w", err)
 	}
 
-	for _, entry := range fileList.Deletes {
-		if strings.Contains(entry.Name, "..") {
-			slog.Print("Skipping %s, has .. inside it", entry.Name)
-			continue
-		}
-		fi, err := os.Stat(entry.Name)
-		if err != nil {
-			if os.IsNotExist(err) {
-				continue
-			}
-			return fmt.Errorf("stat %s: %w", entry.Name, err)
-		}
-		if fi.IsDir() {
-			slog.Print("Skipping deleting %s, it is a directory", entry.Name)
-			continue
-		}
-		err = os.Remove(entry.Name)
-		if err != nil {
-			slog.Print("Failed to delete %s: %s", entry.Name, err)
-			continue
-		}
-		slog.Print("%s removed", entry.Name)
+	err = c.deleteEntries(fileList.Deletes)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	err = c.cleanOrphans(fileList)
+	if err != nil {
+		slog.Print("Failed to check for old files: %s", err)
 	}
 	gui.SetProgress(100)
 
 	c.cfg.Version = fileList.Version
-	err = c.cfg.Save()
+	err = c.saveConfig()
 	if err != nil {
 		slog.Print("Failed to save version to %s.ini: %s", c.baseName, err)
 	}
+	err = c.recordPatch(fileList.Version)
+	if err != nil {
+		slog.Print("Failed to record patch history: %s", err)
+	}
 
 	if totalDownloaded == 0 {
 		c.patchSummary = fmt.Sprintf("Finished patch in %0.2f seconds", time.Since(start).Seconds())
@@ -581,56 +557,28 @@ func (c *Client) patch() error {
 }
 
 func (c *Client) downloadPatchFile(entry FileEntry) error {
-	client := c.httpClient
-	if !isMapsDownloaded && strings.HasPrefix(strings.ToLower(entry.Name), "maps/") {
-		slog.Print("Downloading maps.zip...")
-		url := fmt.Sprintf("%s/maps.zip", c.patcherUrl)
-		resp, err := client.Get(url)
-		if err != nil {
-			return fmt.Errorf("download %s: %w", url, err)
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
-		}
-
-		w, err := os.Create("maps.zip")
-		if err != nil {
-			return fmt.Errorf("create %s: %w", entry.Name, err)
-		}
-		defer w.Close()
-
-		_, err = io.Copy(w, resp.Body)
-		if err != nil {
-			return fmt.Errorf("write %s: %w", entry.Name, err)
-		}
-
-		//unzip it
-		err = unpack("maps.zip", ".")
-		if err != nil {
-			return fmt.Errorf("unzip %s: %w", entry.Name, err)
-		}
-
-		isMapsDownloaded = true
-		return nil
-	}
 	slog.Printf("%s (%s)\n", entry.Name, generateSize(entry.Size))
 
-	w, err := os.Create(entry.Name)
+	name, err := c.safePath(entry.Name)
 	if err != nil {
-		return fmt.Errorf("create %s: %w", entry.Name, err)
+		return fmt.Errorf("download %s: %w", entry.Name, err)
 	}
-	defer w.Close()
 
-	url := fmt.Sprintf("%s/%s/%s", c.cacheFileList.DownloadPrefix, c.clientVersion, entry.Name)
-	resp, err := client.Get(url)
+	resp, err := c.getPatchFile(c.httpClient, entry.Name)
 	if err != nil {
-		return fmt.Errorf("download %s: %w", url, err)
+		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+
+	err = unlinkExisting(name)
+	if err != nil {
+		return err
 	}
+	w, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	defer w.Close()
 
 	_, err = io.Copy(w, resp.Body)
 	if err != nil {
@@ -639,6 +587,81 @@ func (c *Client) downloadPatchFile(entry FileEntry) error {
 	return nil
 }
 
+// getPatchFile requests name with client from the filelist download prefix, falling back to each mirror
+func (c *Client) getPatchFile(client *http.Client, name string) (*http.Response, error) {
+	prefixes := append([]string{c.cacheFileList.DownloadPrefix}, c.mirrors...)
+	var lastErr error
+	for _, prefix := range prefixes {
+		url := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(prefix, "/"), c.clientVersion, name)
+		resp, err := client.Get(url)
+		if err != nil {
+			lastErr = fmt.Errorf("download %s: %w", url, err)
+			slog.Debug("Download failed, trying next mirror", "url", url, "error", err)
+			continue
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+			slog.Debug("Download failed, trying next mirror", "url", url, "status", resp.StatusCode)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// downloadAll downloads entries using up to maxDownloads at once, calling done after each file
+func (c *Client) downloadAll(entries []FileEntry, done func(entry FileEntry)) error {
+	workers := c.maxDownloads
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(entries) {
+		workers = len(entries)
+	}
+
+	queue := make(chan FileEntry)
+	errs := make(chan error, workers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range queue {
+				err := c.downloadPatchFile(entry)
+				if err != nil {
+					errs <- err
+					return
+				}
+				done(entry)
+			}
+		}()
+	}
+
+	var err error
+	for _, entry := range entries {
+		select {
+		case <-c.patchCtx.Done():
+			err = fmt.Errorf("patch cancelled")
+		case err = <-errs:
+		case queue <- entry:
+			continue
+		}
+		break
+	}
+	close(queue)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-errs:
+		return err
+	default:
+	}
+	return nil
+}
+
 func md5Checksum(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -696,7 +719,7 @@ func (c *Client) fetchUsername() (string, error) {
 }
 
 // unpack unzips the provided path
-func unpack(srcFile string, dstDir string) error {
+func unpack(ctx context.Context, srcFile string, dstDir string, keep func(path string) bool) error {
 	ext := filepath.Ext(srcFile)
 	if ext != ".zip" {
 		return fmt.Errorf("invalid extension: %s", ext)
@@ -706,9 +729,34 @@ func unpack(srcFile string, dstDir string) error {
 		return fmt.Errorf("open: %w", err)
 	}
 	defer r.Close()
+	return unpackReader(ctx, &r.Reader, dstDir, keep)
+}
+
+// unpackReader extracts every file in r into dstDir. Files keep returns true
+// for, given their path below dstDir, are left as they are on disk. keep may be nil.
+// It stops between files once ctx is done
+func unpackReader(ctx context.Context, r *zip.Reader, dstDir string, keep func(path string) bool) error {
+	err := os.MkdirAll(dstDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("mkdirall: %w", err)
+	}
+	root, err := filepath.Abs(dstDir)
+	if err != nil {
+		return fmt.Errorf("abs: %w", err)
+	}
 
 	for _, f := range r.File {
-		filePath := filepath.Join(dstDir, f.Name)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink, refusing to extract", f.Name)
+		}
+		rel, err := safeJoin(root, f.Name)
+		if err != nil {
+			return fmt.Errorf("unsafe entry: %w", err)
+		}
+		filePath := filepath.Join(dstDir, rel)
 		if f.FileInfo().IsDir() {
 			err := os.MkdirAll(filePath, os.ModePerm)
 			if err != nil {
@@ -717,11 +765,19 @@ func unpack(srcFile string, dstDir string) error {
 			continue
 		}
 
+		if keep != nil && keep(filePath) {
+			continue
+		}
+
 		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
 			return fmt.Errorf("mkdirall: %w", err)
 		}
 
-		outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		err = unlinkExisting(filePath)
+		if err != nil {
+			return err
+		}
+		outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm())
 		if err != nil {
 			return fmt.Errorf("openfile: %w", err)
 		}
@@ -750,5 +806,6 @@ func (c *Client) Done() error {
 	if c.patchCancel != nil {
 		c.patchCancel()
 	}
+	c.stopSeeding()
 	return nil
 }
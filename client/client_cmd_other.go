@@ -4,10 +4,108 @@
 package client
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xackery/starteq/slog"
 )
 
-func (c *Client) createCommand(isHidden bool, name string, arg ...string) *exec.Cmd {
-	cmd := exec.Command(name, arg...)
-	return cmd
+// createCommand runs windows executables through wine (or proton), other commands run natively
+func (c *Client) createCommand(isHidden bool, name string, arg ...string) (*exec.Cmd, error) {
+	if !strings.EqualFold(filepath.Ext(name), ".exe") {
+		return exec.Command(name, arg...), nil
+	}
+	runner, env, err := c.wineRunner()
+	if err != nil {
+		return nil, fmt.Errorf("%s needs wine: %w", filepath.Base(name), err)
+	}
+	args := append([]string{}, runner[1:]...)
+	args = append(args, name)
+	for _, a := range arg {
+		args = append(args, winePath(a))
+	}
+	slog.Debug("Running through wine", "runner", runner[0], "exe", name)
+	cmd := exec.Command(runner[0], args...)
+	cmd.Env = append(cmd.Environ(), env...)
+	return cmd, nil
+}
+
+// wineRunner returns the configured or detected wine command and the environment it needs
+func (c *Client) wineRunner() ([]string, []string, error) {
+	env := []string{}
+	if os.Getenv("WINEDEBUG") == "" {
+		env = append(env, "WINEDEBUG=-all")
+	}
+
+	runner := []string{}
+	if c.cfg.WineRunner != "" {
+		var err error
+		runner, err = splitArgs(c.cfg.WineRunner)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[wine] runner: %w", err)
+		}
+	}
+	if len(runner) == 0 {
+		for _, name := range []string{"wine", "wine64"} {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				continue
+			}
+			runner = []string{path}
+			break
+		}
+	}
+	if len(runner) == 0 {
+		return nil, nil, fmt.Errorf("wine not found, set [wine] runner in %s.ini", c.baseName)
+	}
+
+	prefix := c.cfg.WinePrefix
+	if strings.HasPrefix(prefix, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			prefix = filepath.Join(home, prefix[2:])
+		}
+	}
+
+	if strings.HasPrefix(strings.ToLower(filepath.Base(runner[0])), "proton") {
+		// proton is invoked as "proton run game.exe" and keeps its prefix in compatdata
+		if len(runner) == 1 {
+			runner = append(runner, "run")
+		}
+		if prefix == "" {
+			prefix = filepath.Join(c.currentPath, "compatdata")
+		}
+		err := os.MkdirAll(prefix, os.ModePerm)
+		if err != nil {
+			return nil, nil, fmt.Errorf("mkdir %s: %w", prefix, err)
+		}
+		env = append(env, "STEAM_COMPAT_DATA_PATH="+prefix)
+		if os.Getenv("STEAM_COMPAT_CLIENT_INSTALL_PATH") == "" {
+			home, err := os.UserHomeDir()
+			if err == nil {
+				env = append(env, "STEAM_COMPAT_CLIENT_INSTALL_PATH="+filepath.Join(home, ".steam", "steam"))
+			}
+		}
+		return runner, env, nil
+	}
+
+	if prefix != "" {
+		env = append(env, "WINEPREFIX="+prefix)
+	}
+	return runner, env, nil
+}
+
+// winePath converts an existing absolute unix path to the Z: drive wine maps to /
+func winePath(arg string) string {
+	if !filepath.IsAbs(arg) {
+		return arg
+	}
+	_, err := os.Stat(arg)
+	if err != nil {
+		return arg
+	}
+	return "Z:" + strings.ReplaceAll(arg, "/", `\`)
 }
@@ -8,8 +8,8 @@ import (
 	"syscall"
 )
 
-func (c *Client) createCommand(isHidden bool, name string, arg ...string) *exec.Cmd {
+func (c *Client) createCommand(isHidden bool, name string, arg ...string) (*exec.Cmd, error) {
 	cmd := exec.Command(name, arg...)
 	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: isHidden}
-	return cmd
+	return cmd, nil
 }
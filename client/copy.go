@@ -7,61 +7,99 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/xackery/starteq/gui"
 	"github.com/xackery/starteq/slog"
 )
 
 func (c *Client) CopyBackup(rofPath string) error {
-	slog.Printf("Copying files from everquest_rof2...")
-	// copy all files in everquest_rof2 to current path
-	err := filepath.Walk(rofPath, func(path string, info os.FileInfo, err error) error {
+	slog.Printf("Copying files from %s...", rofPath)
+	return c.copyInstall(rofPath, false)
+}
+
+// copyInstall copies every file in src into the game folder, keeping the
+// folder layout below src. With link set, files are hardlinked instead and
+// copied only if the folders are on different drives. Settings files are
+// always copied, eqgame.exe rewrites those in place
+func (c *Client) copyInstall(src string, link bool) error {
+	need := uint64(0)
+	total := uint64(0)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-
 		if info.IsDir() {
 			return nil
 		}
-
-		dst := strings.TrimPrefix(path, "everquest_rof2/")
-		dst = strings.TrimPrefix(dst, "everquest_rof2\\")
-		dst = strings.TrimPrefix(dst, "../everquest_rof2\\")
-		dst = strings.TrimPrefix(dst, "..\\everquest_rof2\\")
-
-		fi, err := os.Stat(dst)
-		if err == nil {
-			// check if file mod date is newer and file size is around same
-			if fi.ModTime().After(info.ModTime()) && fi.Size() > info.Size()-100 && fi.Size() < info.Size()+100 {
-				return nil
-			}
+		dst, err := c.installDst(src, path)
+		if err != nil {
+			return err
 		}
-
-		r, err := os.Open(path)
+		if dst == "" || isBackupCopied(dst, info) {
+			return nil
+		}
+		need += uint64(info.Size())
+		total += uint64(info.Size())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk: %w", err)
+	}
+	if !link {
+		err = c.checkSpace(c.currentPath, need)
 		if err != nil {
-			return fmt.Errorf("open %s: %w", path, err)
+			return err
 		}
-		defer r.Close()
+	}
 
-		err = os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+	gui.SetProgress(0)
+	defer gui.SetProgress(100)
+	done := uint64(0)
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return fmt.Errorf("mkdir %s: %w", filepath.Dir(path), err)
+			return err
 		}
-
-		w, err := os.Create(dst)
+		if info.IsDir() {
+			return nil
+		}
+		dst, err := c.installDst(src, path)
 		if err != nil {
-			return fmt.Errorf("create %s: %w", dst, err)
+			return err
 		}
-		defer w.Close()
-
-		buf := make([]byte, 1024*1024)
-		_, err = io.CopyBuffer(w, r, buf)
+		if dst == "" || isBackupCopied(dst, info) {
+			return nil
+		}
+		err = os.MkdirAll(filepath.Dir(dst), os.ModePerm)
 		if err != nil {
-			return fmt.Errorf("copy %s: %w", dst, err)
+			return fmt.Errorf("mkdir %s: %w", filepath.Dir(dst), err)
 		}
-		err = w.Sync()
+		err = unlinkExisting(dst)
 		if err != nil {
-			return fmt.Errorf("sync %s: %w", dst, err)
+			return err
+		}
+
+		if link && !isSettingsFile(path) {
+			err = os.Link(path, dst)
+			if err == nil {
+				need -= uint64(info.Size())
+				done += uint64(info.Size())
+				gui.SetProgress(int(float64(done) / float64(total) * 100))
+				return nil
+			}
+			slog.Print("Failed to hardlink %s, copying instead: %s", path, err)
+			link = false
+			err = c.checkSpace(c.currentPath, need)
+			if err != nil {
+				return err
+			}
 		}
 
+		err = copyFile(path, dst)
+		if err != nil {
+			return err
+		}
+		need -= uint64(info.Size())
+		done += uint64(info.Size())
+		gui.SetProgress(int(float64(done) / float64(total) * 100))
 		return nil
 	})
 	if err != nil {
@@ -70,3 +108,82 @@ func (c *Client) CopyBackup(rofPath string) error {
 
 	return nil
 }
+
+// installDst returns where path, a file inside src, is copied to. An empty
+// result means the file is skipped because it belongs to the launcher or is
+// protected and already exists
+func (c *Client) installDst(src string, path string) (string, error) {
+	rel, err := filepath.Rel(src, path)
+	if err != nil {
+		return "", fmt.Errorf("rel %s: %w", path, err)
+	}
+	dst, err := c.safePath(filepath.ToSlash(rel))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	if c.isLauncherFile(rel) {
+		return "", nil
+	}
+	if c.isProtected(rel) {
+		_, err = os.Stat(dst)
+		if err == nil {
+			return "", nil
+		}
+	}
+	return dst, nil
+}
+
+// copyFile copies src to dst
+func copyFile(src string, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", src, err)
+	}
+	defer r.Close()
+
+	w, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	defer w.Close()
+
+	buf := make([]byte, 1024*1024)
+	_, err = io.CopyBuffer(w, r, buf)
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", dst, err)
+	}
+	err = w.Sync()
+	if err != nil {
+		return fmt.Errorf("sync %s: %w", dst, err)
+	}
+	return nil
+}
+
+// unlinkExisting removes path before it is rewritten, so a file hardlinked by
+// an import is replaced instead of changing the install it was imported from
+func unlinkExisting(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// isSettingsFile returns true for files eqgame.exe saves to in place, such as
+// eqclient.ini and UI_*.ini, which a hardlink would share with the source
+func isSettingsFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ini")
+}
+
+// isBackupCopied returns true if dst is already a copy of the backup file
+func isBackupCopied(dst string, info os.FileInfo) bool {
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return false
+	}
+	if os.SameFile(fi, info) {
+		return true
+	}
+	// check if file mod date is newer and file size is around same
+	return fi.ModTime().After(info.ModTime()) && fi.Size() > info.Size()-100 && fi.Size() < info.Size()+100
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xackery/starteq/slog"
+)
+
+// deleteSummary counts what deleteEntries did
+type deleteSummary struct {
+	files     int
+	folders   int
+	protected int
+	failed    int
+}
+
+// deleteEntries removes the files and folders named by the filelist deletes.
+// Names may be glob patterns, folders are removed with everything inside them,
+// and nothing outside the game folder, protected or belonging to the launcher
+// is touched
+func (c *Client) deleteEntries(entries []FileEntry) error {
+	summary := &deleteSummary{}
+	for _, entry := range entries {
+		select {
+		case <-c.patchCtx.Done():
+			return fmt.Errorf("patch cancelled")
+		default:
+		}
+		name, err := c.safePath(entry.Name)
+		if err != nil {
+			slog.Print("Skipping deleting %s: %s", entry.Name, err)
+			continue
+		}
+		matches := []string{name}
+		if strings.ContainsAny(name, "*?[") {
+			matches, err = filepath.Glob(name)
+			if err != nil {
+				slog.Print("Skipping deleting %s: %s", entry.Name, err)
+				continue
+			}
+		}
+		for _, match := range matches {
+			// a match may have been found through a symlink the pattern didn't name
+			match, err = c.safePath(filepath.ToSlash(match))
+			if err != nil {
+				slog.Print("Skipping deleting %s: %s", entry.Name, err)
+				continue
+			}
+			c.deletePath(match, summary)
+		}
+	}
+	if summary.files+summary.folders+summary.protected+summary.failed > 0 {
+		slog.Print("Deleted %d files and %d folders, kept %d protected, %d failed", summary.files, summary.folders, summary.protected, summary.failed)
+	}
+	return nil
+}
+
+// deletePath removes a single file, or a folder and everything inside it
+func (c *Client) deletePath(name string, summary *deleteSummary) {
+	rel, err := c.gameRelPath(name)
+	if err != nil {
+		slog.Print("Skipping deleting %s: %s", name, err)
+		return
+	}
+	if c.isLauncherFile(rel) {
+		slog.Print("Skipping deleting %s, it belongs to %s", rel, c.baseName)
+		return
+	}
+	if c.isProtected(rel) {
+		slog.Print("Skipping deleting %s, it is protected", rel)
+		summary.protected++
+		return
+	}
+	fi, err := os.Lstat(rel)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Print("Failed to delete %s: %s", rel, err)
+			summary.failed++
+		}
+		return
+	}
+	if !fi.IsDir() {
+		err = os.Remove(rel)
+		if err != nil {
+			slog.Print("Failed to delete %s: %s", rel, err)
+			summary.failed++
+			return
+		}
+		slog.Print("%s removed", rel)
+		summary.files++
+		return
+	}
+
+	dirs := []string{}
+	err = filepath.WalkDir(rel, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Print("Failed to delete %s: %s", path, err)
+			summary.failed++
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if c.isProtected(path) {
+			slog.Print("Skipping deleting %s, it is protected", path)
+			summary.protected++
+			return nil
+		}
+		err = os.Remove(path)
+		if err != nil {
+			slog.Print("Failed to delete %s: %s", path, err)
+			summary.failed++
+			return nil
+		}
+		summary.files++
+		return nil
+	})
+	if err != nil {
+		slog.Print("Failed to delete %s: %s", rel, err)
+		summary.failed++
+	}
+
+	// deepest first, folders still holding protected files are kept
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		err = os.Remove(dir)
+		if err != nil {
+			continue
+		}
+		summary.folders++
+	}
+	_, err = os.Lstat(rel)
+	if err == nil {
+		slog.Print("%s folder kept, it still holds protected or locked files", rel)
+		return
+	}
+	slog.Print("%s folder removed", rel)
+}
+
+// gameRelPath returns name relative to the game folder, or an error if it is
+// the game folder itself or outside of it, including through a symlink
+func (c *Client) gameRelPath(name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("abs: %w", err)
+	}
+	rel, err := filepath.Rel(c.currentPath, abs)
+	if err != nil {
+		return "", fmt.Errorf("not inside %s", c.currentPath)
+	}
+	if rel == "." {
+		return "", fmt.Errorf("it is the game folder")
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("not inside %s", c.currentPath)
+	}
+	err = checkSymlinks(c.currentPath, rel)
+	if err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// isLauncherFile returns true for the launcher's own executable, config, state and logs
+func (c *Client) isLauncherFile(rel string) bool {
+	if strings.ContainsRune(rel, filepath.Separator) {
+		return false
+	}
+	return strings.HasPrefix(strings.ToLower(rel), strings.ToLower(c.baseName)) || strings.HasPrefix(strings.ToLower(rel), "."+strings.ToLower(c.baseName))
+}
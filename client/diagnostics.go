@@ -0,0 +1,191 @@
+package client
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/xackery/starteq/slog"
+)
+
+// ExportDiagnostics writes a zip with logs, sanitized settings and install
+// state to the current path, returning the archive name
+func (c *Client) ExportDiagnostics() (string, error) {
+	name := fmt.Sprintf("%s-diagnostics-%s.zip", c.baseName, time.Now().Format("20060102-150405"))
+	slog.Print("Exporting diagnostics to %s", name)
+	slog.Dump(c.baseName + ".txt")
+
+	w, err := os.Create(name)
+	if err != nil {
+		return "", fmt.Errorf("create %s: %w", name, err)
+	}
+	err = c.writeDiagnostics(w)
+	closeErr := w.Close()
+	if err == nil && closeErr != nil {
+		err = fmt.Errorf("close %s: %w", name, closeErr)
+	}
+	if err != nil {
+		// don't leave a truncated zip behind for players to attach
+		os.Remove(name)
+		return "", err
+	}
+	slog.Print("Diagnostics saved to %s", name)
+	return name, nil
+}
+
+// writeDiagnostics writes the diagnostics zip to w
+func (c *Client) writeDiagnostics(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	files := map[string][]byte{
+		"system.txt":     c.diagSystem(),
+		"log.txt":        []byte(strings.Join(slog.Lines(), "")),
+		"mismatches.txt": c.diagMismatches(),
+		"files.txt":      c.diagListing(),
+	}
+
+	logPaths, _ := filepath.Glob(c.baseName + ".txt*")
+	for _, path := range logPaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		files["logs/"+path] = data
+	}
+
+	data, err := os.ReadFile(c.baseName + ".ini")
+	if err == nil {
+		files[c.baseName+".ini"] = sanitizeINI(data, "pass", "token", "secret", "user", "profile")
+	}
+	data, err = os.ReadFile(c.statePath())
+	if err == nil {
+		files[c.statePath()] = data
+	}
+	data, err = os.ReadFile("eqhost.txt")
+	if err == nil {
+		files["eqhost.txt"] = data
+	}
+	data, err = os.ReadFile("eqlsPlayerData.ini")
+	if err == nil {
+		files["eqlsPlayerData.ini"] = sanitizeINI(data, "username", "password")
+	}
+
+	for path, data := range files {
+		fw, err := zw.Create(path)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", path, err)
+		}
+		_, err = fw.Write(data)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return fmt.Errorf("close zip: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) diagSystem() []byte {
+	out := fmt.Sprintf("launcher: %s %s\n", c.baseName, c.version)
+	out += fmt.Sprintf("patcher url: %s\n", c.patcherUrl)
+	out += fmt.Sprintf("client version: %s\n", c.clientVersion)
+	out += fmt.Sprintf("patch version: %s\n", c.cfg.Version)
+	out += fmt.Sprintf("os: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	out += fmt.Sprintf("go: %s\n", runtime.Version())
+	out += fmt.Sprintf("cpus: %d\n", runtime.NumCPU())
+	out += fmt.Sprintf("path: %s\n", c.currentPath)
+	out += fmt.Sprintf("time: %s\n", time.Now().Format(time.RFC3339))
+	return []byte(out)
+}
+
+// diagMismatches compares local files against the filelist of the last patch.
+// It doesn't download one, so an export can't change what a running patch uses
+func (c *Client) diagMismatches() []byte {
+	fileList := c.cachedFileList()
+	if fileList == nil || len(fileList.Downloads) == 0 {
+		return []byte("no file list downloaded yet, patch before exporting to compare files\n")
+	}
+	out := fmt.Sprintf("filelist version: %s\n", fileList.Version)
+	mismatches := 0
+	for _, entry := range fileList.Downloads {
+		hash, err := md5Checksum(entry.Name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				out += fmt.Sprintf("missing %s\n", entry.Name)
+			} else {
+				out += fmt.Sprintf("error %s: %s\n", entry.Name, err)
+			}
+			mismatches++
+			continue
+		}
+		if strings.EqualFold(hash, entry.Md5) {
+			continue
+		}
+		if entry.CreateOnly || c.isProtected(entry.Name) {
+			out += fmt.Sprintf("protected %s: have %s, want %s\n", entry.Name, hash, entry.Md5)
+			continue
+		}
+		out += fmt.Sprintf("mismatch %s: have %s, want %s\n", entry.Name, hash, entry.Md5)
+		mismatches++
+	}
+	out += fmt.Sprintf("%d of %d files differ\n", mismatches, len(fileList.Downloads))
+	return []byte(out)
+}
+
+// diagListing lists every file under the current path with size and date
+func (c *Client) diagListing() []byte {
+	buf := &bytes.Buffer{}
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintf(buf, "error %s: %s\n", path, err)
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		fmt.Fprintf(buf, "%s\t%d\t%s\n", filepath.ToSlash(path), info.Size(), info.ModTime().Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(buf, "walk: %s\n", err)
+	}
+	return buf.Bytes()
+}
+
+// sanitizeINI redacts the value of any key, or every key in a section, containing one of keywords
+func sanitizeINI(data []byte, keywords ...string) []byte {
+	out := &bytes.Buffer{}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.ToLower(trimmed)
+		}
+		index := strings.Index(line, "=")
+		if index < 0 || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			out.WriteString(line + "\n")
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:index]))
+		for _, keyword := range keywords {
+			if strings.Contains(key, keyword) || strings.Contains(section, keyword) {
+				line = line[:index+1] + " <redacted>"
+				break
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes()
+}
@@ -7,13 +7,27 @@ type FileList struct {
 	Deletes        []FileEntry `yaml:"deletes"`
 	Downloads      []FileEntry `yaml:"downloads"`
 	Unpacks        []FileEntry `yaml:"unpacks"`
+	Bundles        []Bundle    `yaml:"bundles"`
+}
+
+// Bundle is a zip of many files, such as maps, that is downloaded and
+// extracted as one piece whenever its version changes
+type Bundle struct {
+	Name     string `yaml:"name"`    // zip name under the download prefix
+	Version  string `yaml:"version"` // extracted again whenever this changes
+	Md5      string `yaml:"md5"`
+	Size     int    `yaml:"size"`
+	Prefix   string `yaml:"prefix"` // folder of the downloads the bundle provides, such as maps/
+	Dir      string `yaml:"dir"`    // folder extracted into, the game folder if empty
+	isLegacy bool   // maps.zip at the patcher url, used by filelists without bundles
 }
 
 // FileEntry is an entry inside FileList
 type FileEntry struct {
-	Name string `yaml:"name"`
-	Md5  string `yaml:"md5"`
-	Date string `yaml:"date"`
-	Zip  string `yaml:"zip"`
-	Size int    `yaml:"size"`
+	Name       string `yaml:"name"`
+	Md5        string `yaml:"md5"`
+	Date       string `yaml:"date"`
+	Zip        string `yaml:"zip"`
+	Size       int    `yaml:"size"`
+	CreateOnly bool   `yaml:"createonly"` // only downloaded when missing, so players may customize it
 }
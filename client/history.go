@@ -0,0 +1,172 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+	"gopkg.in/yaml.v3"
+)
+
+// FileListIndex represents a filelist_<client>_index.yml listing the filelist
+// versions a server still publishes, newest first
+type FileListIndex struct {
+	Versions []FileListVersion `yaml:"versions"`
+}
+
+// FileListVersion is an entry inside FileListIndex
+type FileListVersion struct {
+	Version string `yaml:"version"`
+	Date    string `yaml:"date"`
+	File    string `yaml:"file"` // filelist for this version, relative to the index
+	Notes   string `yaml:"notes"`
+}
+
+// find returns the entry for version, matching a unique prefix like the 8
+// character versions shown in the log
+func (idx *FileListIndex) find(version string) (*FileListVersion, error) {
+	var found *FileListVersion
+	for i := range idx.Versions {
+		entry := &idx.Versions[i]
+		if strings.EqualFold(entry.Version, version) {
+			return entry, nil
+		}
+		if !strings.HasPrefix(strings.ToLower(entry.Version), strings.ToLower(version)) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("version %s is ambiguous", version)
+		}
+		found = entry
+	}
+	if found == nil {
+		return nil, fmt.Errorf("version %s is not published by the server", version)
+	}
+	return found, nil
+}
+
+// fetchFileListIndex downloads the filelist index for the configured channel,
+// returning it and the prefix it was found under
+func (c *Client) fetchFileListIndex() (*FileListIndex, string, error) {
+	name := fmt.Sprintf("filelist_%s_index.yml", c.clientVersion)
+	resp, prefix, err := c.getChannelFile(name)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	idx := &FileListIndex{}
+	err = yaml.NewDecoder(resp.Body).Decode(idx)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", name, err)
+	}
+	for i, entry := range idx.Versions {
+		if entry.Version == "" || entry.File == "" {
+			return nil, "", fmt.Errorf("%s entry %d needs a version and file", name, i+1)
+		}
+		if strings.Contains(entry.File, "..") {
+			return nil, "", fmt.Errorf("%s entry %d file %q may not contain ..", name, i+1, entry.File)
+		}
+	}
+	return idx, prefix, nil
+}
+
+// fetchPinnedFileList downloads the filelist for the pinned version
+func (c *Client) fetchPinnedFileList() error {
+	idx, prefix, err := c.fetchFileListIndex()
+	if err != nil {
+		return fmt.Errorf("filelist index: %w", err)
+	}
+	entry, err := idx.find(c.cfg.PinVersion)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/%s", prefix, entry.File)
+	slog.Print("Downloading pinned version %s from %s", c.cfg.PinVersion, url)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+	}
+	fileList := &FileList{}
+	err = yaml.NewDecoder(resp.Body).Decode(fileList)
+	if err != nil {
+		return fmt.Errorf("decode filelist: %w", err)
+	}
+	if !strings.EqualFold(fileList.Version, entry.Version) {
+		return fmt.Errorf("%s is version %s, index says %s", entry.File, fileList.Version, entry.Version)
+	}
+	c.setFileList(fileList)
+	return nil
+}
+
+// InstallVersion pins the install to a filelist version from the server's
+// index and patches to it. "latest" or an empty version removes the pin
+func (c *Client) InstallVersion(version string) error {
+	if strings.EqualFold(version, "latest") {
+		version = ""
+	}
+	if version != "" {
+		idx, _, err := c.fetchFileListIndex()
+		if err != nil {
+			return fmt.Errorf("filelist index: %w", err)
+		}
+		entry, err := idx.find(version)
+		if err != nil {
+			return err
+		}
+		version = entry.Version
+	}
+	c.cfg.PinVersion = version
+	err := c.saveConfig()
+	if err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+	if version == "" {
+		slog.Print("Installing the latest version")
+	} else {
+		slog.Print("Installing version %s, automatic patches are paused until latest is installed again", version)
+	}
+	return c.Patch()
+}
+
+// selectVersion asks which published version to install
+func (c *Client) selectVersion() (string, bool) {
+	idx, _, err := c.fetchFileListIndex()
+	if err != nil {
+		slog.Print("Failed to fetch versions: %s", err)
+		gui.MessageBox("Error", "This server does not publish older versions: "+err.Error(), true)
+		return "", false
+	}
+	items := []string{"latest"}
+	versions := []string{"latest"}
+	selected := 0
+	for _, entry := range idx.Versions {
+		item := entry.Version
+		if len(item) > 8 {
+			item = item[0:8]
+		}
+		if entry.Date != "" {
+			item += "  " + entry.Date
+		}
+		if entry.Notes != "" {
+			item += "  " + entry.Notes
+		}
+		if strings.EqualFold(entry.Version, c.cfg.Version) {
+			item += "  (installed)"
+		}
+		if strings.EqualFold(entry.Version, c.cfg.PinVersion) {
+			selected = len(items)
+		}
+		items = append(items, item)
+		versions = append(versions, entry.Version)
+	}
+	index, ok := gui.SelectVersion("Install version", items, selected)
+	if !ok {
+		return "", false
+	}
+	return versions[index], true
+}
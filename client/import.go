@@ -0,0 +1,225 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+)
+
+// importKeyFiles are hashed against the torrent to confirm a folder holds the
+// RoF2 client. Server patches rarely touch these, unlike spells or strings
+var importKeyFiles = []string{"eqgame.exe", "eqmain.dll", "EQGraphicsDX9.dll"}
+
+// ImportInstall verifies dir holds the RoF2 client, then copies it into the
+// game folder, or hardlinks it when link is set, and patches on top
+func (c *Client) ImportInstall(dir string, link bool) error {
+	err := c.verifyInstall(dir)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	return c.importInstall(dir, link)
+}
+
+// importInstall copies or hardlinks dir into the game folder without verifying it
+func (c *Client) importInstall(dir string, link bool) error {
+	if !c.patchMu.TryLock() {
+		return fmt.Errorf("patch already in progress")
+	}
+	defer c.patchMu.Unlock()
+	src, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("abs: %w", err)
+	}
+	if isWithin(c.currentPath, src) {
+		return fmt.Errorf("%s is inside the game folder", src)
+	}
+	if isWithin(src, c.currentPath) {
+		return fmt.Errorf("%s holds the game folder", src)
+	}
+
+	gui.SetPatchMode(true)
+	if link {
+		slog.Print("Linking files from %s...", src)
+	} else {
+		slog.Print("Copying files from %s...", src)
+	}
+	err = c.copyInstall(src, link)
+	gui.SetPatchMode(false)
+	if err != nil {
+		return err
+	}
+
+	// the import may come from another server, so every file is verified
+	slog.Print("Imported %s, verifying it against this server's files", src)
+	c.cfg.Version = ""
+	err = c.runPatch()
+	if err != nil {
+		return fmt.Errorf("patch: %w", err)
+	}
+	return nil
+}
+
+// isWithin returns true if path is dir or below it, comparing case insensitively
+// and after resolving symlinks where possible
+func isWithin(dir string, path string) bool {
+	resolve := func(p string) string {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			abs = p
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			abs = resolved
+		}
+		return strings.ToLower(filepath.Clean(abs))
+	}
+	rel, err := filepath.Rel(resolve(dir), resolve(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// verifyInstall checks the key files in dir against the torrent's piece
+// hashes. Pieces that also cover a missing or resized neighbour file are
+// skipped, but each key file needs at least one piece checked
+func (c *Client) verifyInstall(dir string) error {
+	_, err := os.Stat(filepath.Join(dir, "eqgame.exe"))
+	if err != nil {
+		return fmt.Errorf("eqgame.exe not found in %s", dir)
+	}
+	mi, err := metainfo.Load(bytes.NewReader(c.torrentData))
+	if err != nil {
+		return fmt.Errorf("metainfo load: %w", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return fmt.Errorf("metainfo info: %w", err)
+	}
+	files := info.UpvertedFiles()
+	offsets := make([]int64, len(files))
+	offset := int64(0)
+	for i, f := range files {
+		offsets[i] = offset
+		offset += f.Length
+	}
+
+	checked := 0
+	for _, key := range importKeyFiles {
+		index := -1
+		for i := range files {
+			if strings.EqualFold(strings.Join(files[i].BestPath(), "/"), key) {
+				index = i
+				break
+			}
+		}
+		if index < 0 || files[index].Length == 0 {
+			continue
+		}
+		f := files[index]
+		fi, err := os.Stat(filepath.Join(dir, key))
+		if err != nil {
+			return fmt.Errorf("%s not found in %s", key, dir)
+		}
+		if fi.Size() != f.Length {
+			return fmt.Errorf("%s is %s, the RoF2 client's is %s", key, generateSize(int(fi.Size())), generateSize(int(f.Length)))
+		}
+
+		pieces := 0
+		first := int(offsets[index] / info.PieceLength)
+		last := int((offsets[index] + f.Length - 1) / info.PieceLength)
+		for i := first; i <= last; i++ {
+			isMatch, err := verifyPiece(dir, info.Piece(i), files, offsets)
+			if err != nil {
+				slog.Debug("Skipping piece", "piece", i, "error", err)
+				continue
+			}
+			if !isMatch {
+				return fmt.Errorf("%s does not match the RoF2 client", key)
+			}
+			pieces++
+		}
+		if pieces == 0 {
+			return fmt.Errorf("%s could not be checked, files next to it in the torrent are missing or resized", key)
+		}
+		checked++
+	}
+	if checked == 0 {
+		return fmt.Errorf("no key files to verify in the torrent")
+	}
+	return nil
+}
+
+// verifyPiece hashes the bytes in dir that make up piece p, returning an
+// error if a file it covers is missing or a different size
+func verifyPiece(dir string, p metainfo.Piece, files []metainfo.FileInfo, offsets []int64) (bool, error) {
+	start := p.Offset()
+	end := start + p.Length()
+	h := sha1.New()
+	for i, f := range files {
+		if offsets[i]+f.Length <= start || offsets[i] >= end {
+			continue
+		}
+		path := filepath.Join(dir, filepath.Join(f.BestPath()...))
+		r, err := os.Open(path)
+		if err != nil {
+			return false, fmt.Errorf("open: %w", err)
+		}
+		fi, err := r.Stat()
+		if err != nil {
+			r.Close()
+			return false, fmt.Errorf("stat %s: %w", path, err)
+		}
+		if fi.Size() != f.Length {
+			r.Close()
+			return false, fmt.Errorf("%s is %d bytes, expected %d", path, fi.Size(), f.Length)
+		}
+		from := start
+		if offsets[i] > from {
+			from = offsets[i]
+		}
+		to := end
+		if offsets[i]+f.Length < to {
+			to = offsets[i] + f.Length
+		}
+		_, err = io.Copy(h, io.NewSectionReader(r, from-offsets[i], to-from))
+		r.Close()
+		if err != nil {
+			return false, fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	hash := p.Hash()
+	return bytes.Equal(h.Sum(nil), hash[:]), nil
+}
+
+// selectImport asks for a folder to import and how to bring its files over.
+// Verifying and copying run in the background, like a patch
+func (c *Client) selectImport() {
+	dir, ok := gui.BrowseFolder("Select an existing EverQuest folder")
+	if !ok {
+		return
+	}
+	go func() {
+		slog.Print("Verifying %s...", dir)
+		err := c.verifyInstall(dir)
+		if err != nil {
+			slog.Print("Failed to verify %s: %s", dir, err)
+			if !gui.MessageBoxYesNo("Import install", fmt.Sprintf("%s does not look like a Rain of Fear 2 client:\n%s\n\nImport it anyway?", dir, err)) {
+				return
+			}
+		}
+		link := gui.MessageBoxYesNo("Import install", "Hardlink the files instead of copying them?\nThis uses no extra disk space, but only works when both folders are on the same drive.\nSettings files such as eqclient.ini are always copied, so the other folder keeps its own settings.")
+		err = c.importInstall(dir, link)
+		if err != nil {
+			slog.Print("Failed to import %s: %s", dir, err)
+		}
+	}()
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xackery/starteq/slog"
+)
+
+const (
+	// hookTimeout is how long a pre_launch or post_exit hook may run before it is killed
+	hookTimeout = 60 * time.Second
+)
+
+// launchCommand builds the eqgame.exe command for account from the launch settings
+func (c *Client) launchCommand(account string) (*exec.Cmd, error) {
+	eqgame := fmt.Sprintf("%s/eqgame.exe", c.currentPath)
+	args := []string{"patchme", "/login:" + account}
+	extra, err := splitArgs(c.cfg.LaunchArgs)
+	if err != nil {
+		return nil, fmt.Errorf("launch_args: %w", err)
+	}
+	args = append(args, extra...)
+
+	name := eqgame
+	if c.cfg.LaunchExe != "" {
+		wrapper, err := splitArgs(c.cfg.LaunchExe)
+		if err != nil {
+			return nil, fmt.Errorf("launch_exe: %w", err)
+		}
+		if len(wrapper) == 0 {
+			return nil, fmt.Errorf("launch_exe is empty")
+		}
+		name = wrapper[0]
+		args = append(append(wrapper[1:], eqgame), args...)
+	}
+
+	cmd, err := c.createCommand(true, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = c.launchDir()
+	cmd.Env = append(cmd.Environ(), c.cfg.LaunchEnv...)
+	cmd.Env = append(cmd.Env, "STARTEQ_ACCOUNT="+account)
+	return cmd, nil
+}
+
+// launchDir returns the working directory eqgame.exe and hooks are started in
+func (c *Client) launchDir() string {
+	if c.cfg.LaunchDir == "" {
+		return c.currentPath
+	}
+	if filepath.IsAbs(c.cfg.LaunchDir) {
+		return c.cfg.LaunchDir
+	}
+	return filepath.Join(c.currentPath, c.cfg.LaunchDir)
+}
+
+// runHook runs a pre_launch or post_exit command and waits for it to finish
+func (c *Client) runHook(kind string, command string, env ...string) error {
+	if command == "" {
+		return nil
+	}
+	args, err := splitArgs(command)
+	if err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	slog.Print("Running %s hook: %s", kind, command)
+	cmd, err := c.createCommand(true, args[0], args[1:]...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	cmd.Dir = c.launchDir()
+	cmd.Env = append(cmd.Environ(), c.cfg.LaunchEnv...)
+	cmd.Env = append(cmd.Env, env...)
+	out := &strings.Builder{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("start %s: %w", kind, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(hookTimeout):
+		cmd.Process.Kill()
+		<-done
+		err = fmt.Errorf("timed out after %s", hookTimeout)
+	}
+	if out.Len() > 0 {
+		slog.Debug("Hook output", "hook", kind, "output", strings.TrimSpace(out.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	return nil
+}
+
+// splitArgs splits a command line on spaces, honoring double and single quotes
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	current := &strings.Builder{}
+	isArg := false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			isArg = true
+		case r == ' ' || r == '\t':
+			if isArg {
+				args = append(args, current.String())
+				current.Reset()
+				isArg = false
+			}
+		default:
+			current.WriteRune(r)
+			isArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if isArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
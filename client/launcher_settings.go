@@ -0,0 +1,207 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xackery/starteq/config"
+	"github.com/xackery/starteq/slog"
+	"gopkg.in/yaml.v3"
+)
+
+// LauncherSettings represents an optional launcher.yml published next to the filelist
+type LauncherSettings struct {
+	LoginHost             string            `yaml:"login_host"`
+	RequiredClientVersion string            `yaml:"required_client_version"`
+	MinLauncherVersion    string            `yaml:"min_launcher_version"`
+	NewsURL               string            `yaml:"news_url"`
+	MaxDownloads          int               `yaml:"max_downloads"`
+	Mirrors               []string          `yaml:"mirrors"`
+	BaseArchive           *BaseArchive      `yaml:"base_archive"`
+	WebSeeds              []string          `yaml:"web_seeds"`
+	Trackers              []string          `yaml:"trackers"`
+	Defaults              map[string]string `yaml:"defaults"`
+	Overrides             map[string]string `yaml:"overrides"`
+}
+
+// Validate returns an error if the settings appear off
+func (s *LauncherSettings) Validate() error {
+	problems := []string{}
+	if s.NewsURL != "" {
+		err := config.ValidateURL(s.NewsURL)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("news_url: %s", err))
+		}
+	}
+	for _, mirror := range s.Mirrors {
+		err := config.ValidateURL(mirror)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("mirrors: %s", err))
+		}
+	}
+	for _, seed := range s.WebSeeds {
+		err := config.ValidateURL(seed)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("web_seeds: %s", err))
+		}
+	}
+	for _, tracker := range s.Trackers {
+		err := config.ValidateTracker(tracker)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("trackers: %s", err))
+		}
+	}
+	if s.BaseArchive != nil {
+		err := s.BaseArchive.Validate()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("base_archive: %s", err))
+		}
+	}
+	if s.MaxDownloads < 0 {
+		problems = append(problems, "max_downloads must not be negative")
+	}
+	if s.LoginHost != "" && strings.ContainsAny(s.LoginHost, " \t\r\n") {
+		problems = append(problems, fmt.Sprintf("login_host %q must not contain spaces", s.LoginHost))
+	}
+	if s.MinLauncherVersion != "" {
+		_, err := parseVersion(s.MinLauncherVersion)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("min_launcher_version: %s", err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// fetchLauncherSettings downloads launcher.yml, returning nil if the server does not publish one
+func (c *Client) fetchLauncherSettings() (*LauncherSettings, error) {
+	url := fmt.Sprintf("%s/launcher.yml", c.patcherUrl)
+	slog.Debug("Downloading launcher settings", "url", url)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+	}
+
+	settings := &LauncherSettings{}
+	err = yaml.NewDecoder(resp.Body).Decode(settings)
+	if err != nil {
+		return nil, fmt.Errorf("decode launcher settings: %w", err)
+	}
+	err = settings.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("validate launcher settings: %w", err)
+	}
+	return settings, nil
+}
+
+// serverSettings returns the launcher settings, downloading them if the patch
+// has not done so yet. It returns nil if the server has none or they failed to load
+func (c *Client) serverSettings() *LauncherSettings {
+	settings := c.loadedSettings()
+	if settings != nil {
+		return settings
+	}
+	// fetched without the lock, a patch may apply its own copy meanwhile
+	settings, err := c.fetchLauncherSettings()
+	if err != nil {
+		slog.Print("Failed fetch launcher settings, skipping: %s", err)
+		return nil
+	}
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	if c.launcherSettings == nil {
+		c.launcherSettings = settings
+	}
+	return c.launcherSettings
+}
+
+// loadedSettings returns the launcher settings the patch downloaded, without fetching them
+func (c *Client) loadedSettings() *LauncherSettings {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	return c.launcherSettings
+}
+
+// saveConfig writes the config. Save leaves out the server values
+// applyLauncherSettings merges in, so it runs under the same lock
+func (c *Client) saveConfig() error {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	return c.cfg.Save()
+}
+
+// applyLauncherSettings merges server settings into the config. It returns an
+// error if the server requires something this launcher cannot provide
+func (c *Client) applyLauncherSettings(s *LauncherSettings) error {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	c.launcherSettings = s
+	c.mirrors = c.cfg.Mirrors
+	c.maxDownloads = c.cfg.MaxDownloads
+	if s == nil {
+		return nil
+	}
+
+	if s.RequiredClientVersion != "" && !strings.EqualFold(s.RequiredClientVersion, c.clientVersion) {
+		return fmt.Errorf("server requires client %s, this launcher patches %s", s.RequiredClientVersion, c.clientVersion)
+	}
+
+	overrides := map[string]string{}
+	for key, value := range s.Overrides {
+		overrides[key] = value
+	}
+	if s.LoginHost != "" {
+		overrides["server.login_host"] = s.LoginHost
+	}
+	for _, err := range c.cfg.ApplyRemote(s.Defaults, overrides) {
+		slog.Warn("Ignoring server setting", "error", err)
+	}
+
+	c.mirrors = append(append([]string{}, c.cfg.Mirrors...), s.Mirrors...)
+	c.maxDownloads = c.cfg.MaxDownloads
+	if s.MaxDownloads > 0 && c.maxDownloads > s.MaxDownloads {
+		slog.Debug("Server limits parallel downloads", "requested", c.maxDownloads, "allowed", s.MaxDownloads)
+		c.maxDownloads = s.MaxDownloads
+	}
+	if s.NewsURL != "" {
+		slog.Print("News: %s", s.NewsURL)
+	}
+
+	err := c.applyLoginHost()
+	if err != nil {
+		slog.Print("Failed to update eqhost.txt: %s", err)
+	}
+	return nil
+}
+
+// applyLoginHost points eqhost.txt at the configured login server
+func (c *Client) applyLoginHost() error {
+	if c.cfg.LoginHost == "" {
+		return nil
+	}
+	content := fmt.Sprintf("[LoginServer]\r\nHost=%s", c.cfg.LoginHost)
+	data, err := os.ReadFile("eqhost.txt")
+	if err == nil && strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n")) == strings.ReplaceAll(content, "\r\n", "\n") {
+		return nil
+	}
+	err = unlinkExisting("eqhost.txt")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile("eqhost.txt", []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("write eqhost.txt: %w", err)
+	}
+	slog.Print("Set login server to %s in eqhost.txt", c.cfg.LoginHost)
+	return nil
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+)
+
+// PlayProfile launches every account in the named launch profile
+func (c *Client) PlayProfile(name string) error {
+	accounts, ok := c.cfg.Profiles[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("launch profile %s not found", name)
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("launch profile %s has no accounts", name)
+	}
+	return c.PlayAccounts(accounts)
+}
+
+// PlayAccounts launches one eqgame.exe per account, waiting launch_delay seconds between each
+func (c *Client) PlayAccounts(accounts []string) error {
+	gui.LogClear()
+	slog.Print("Launching %d EverQuest instances from %s", len(accounts), c.currentPath)
+	failed := 0
+	for i, account := range accounts {
+		if i > 0 && c.cfg.LaunchDelay > 0 {
+			slog.Print("Waiting %d seconds before launching instance %d", c.cfg.LaunchDelay, i+1)
+			select {
+			case <-time.After(time.Duration(c.cfg.LaunchDelay) * time.Second):
+			case <-c.ctx.Done():
+				return fmt.Errorf("launcher closing")
+			}
+		}
+		err := c.launch(account)
+		if err != nil {
+			slog.Print("Failed to launch instance %d: %s", i+1, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d instances failed to launch", failed, len(accounts))
+	}
+	return nil
+}
+
+// launch starts eqgame.exe logged in as account and tracks it until it exits.
+// Account names are kept out of the log, which ends up in diagnostics
+func (c *Client) launch(account string) error {
+	// the account is reserved while eqgame.exe starts up, so a second click
+	// on play during startup doesn't launch it twice
+	c.gameMu.Lock()
+	_, isRunning := c.games[account]
+	if !isRunning {
+		c.games[account] = nil
+		c.gameSeq++
+	}
+	seq := c.gameSeq
+	c.gameMu.Unlock()
+	if isRunning {
+		slog.Print("EverQuest is already running for this account, skipping")
+		return nil
+	}
+
+	p, err := c.startAccount(account, seq)
+	if err != nil {
+		c.gameMu.Lock()
+		delete(c.games, account)
+		c.gameMu.Unlock()
+		return err
+	}
+
+	c.gameMu.Lock()
+	c.games[account] = p
+	c.gameMu.Unlock()
+	go func() {
+		<-p.done
+		c.gameMu.Lock()
+		delete(c.games, account)
+		c.gameMu.Unlock()
+		err := c.runHook("post_exit", c.cfg.PostExit, "STARTEQ_ACCOUNT="+account, fmt.Sprintf("STARTEQ_EXIT_CODE=%d", p.exitCode))
+		if err != nil {
+			slog.Print("Failed to run post_exit hook: %s", err)
+		}
+	}()
+	return nil
+}
+
+// startAccount runs the pre_launch hook and starts eqgame.exe for account
+func (c *Client) startAccount(account string, seq int) (*gameProcess, error) {
+	cmd, err := c.launchCommand(account)
+	if err != nil {
+		return nil, fmt.Errorf("launch command: %w", err)
+	}
+	err = c.runHook("pre_launch", c.cfg.PreLaunch, "STARTEQ_ACCOUNT="+account)
+	if err != nil {
+		return nil, fmt.Errorf("hook: %w", err)
+	}
+	p, err := c.startGame(fmt.Sprintf("eqgame.exe #%d", seq), cmd)
+	if err != nil {
+		return nil, fmt.Errorf("start eqgame.exe: %w", err)
+	}
+	return p, nil
+}
+
+// knownAccounts returns every account from launch profiles and eqlsPlayerData.ini, sorted
+func (c *Client) knownAccounts() []string {
+	seen := map[string]bool{}
+	accounts := []string{}
+	username, _ := c.fetchUsername()
+	if username != "" {
+		seen[username] = true
+		accounts = append(accounts, username)
+	}
+	for _, profile := range c.cfg.Profiles {
+		for _, account := range profile {
+			if seen[account] {
+				continue
+			}
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// defaultAccounts returns the accounts to preselect, the first launch profile
+func (c *Client) defaultAccounts() []string {
+	names := c.cfg.ProfileNames()
+	if len(names) == 0 {
+		return nil
+	}
+	return c.cfg.Profiles[names[0]]
+}
@@ -0,0 +1,20 @@
+package client
+
+import "github.com/xackery/starteq/torrent"
+
+// Option changes how New sets up a client
+type Option func(c *Client)
+
+// WithTorrenter replaces the torrent backend, such as with a torrent.Mock
+func WithTorrenter(t torrent.Torrenter) Option {
+	return func(c *Client) {
+		c.torrenter = t
+	}
+}
+
+// WithTorrentData replaces the embedded rof2.torrent
+func WithTorrentData(data []byte) Option {
+	return func(c *Client) {
+		c.torrentData = data
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+)
+
+// manifestKey is how a file is stored in the installed manifest
+func manifestKey(name string) string {
+	return filepath.ToSlash(name)
+}
+
+// recordInstalled adds files the launcher downloaded to the installed
+// manifest. Create only entries belong to the player once seeded and are left out
+func (c *Client) recordInstalled(entries []FileEntry) error {
+	return c.updateState(func(state *State) {
+		if state.Installed == nil {
+			state.Installed = map[string]string{}
+		}
+		for _, entry := range entries {
+			if entry.CreateOnly {
+				continue
+			}
+			name, err := c.safePath(entry.Name)
+			if err != nil {
+				continue
+			}
+			state.Installed[manifestKey(name)] = strings.ToLower(entry.Md5)
+		}
+	})
+}
+
+// cleanOrphans finds installed files that are no longer in fileList and,
+// depending on the orphans setting, removes them. Files changed since they
+// were installed, protected files and files the launcher did not install are
+// never removed
+func (c *Client) cleanOrphans(fileList *FileList) error {
+	state, err := c.loadState()
+	if err != nil {
+		return err
+	}
+	if len(state.Installed) == 0 {
+		return nil
+	}
+
+	current := map[string]bool{}
+	for _, entry := range fileList.Downloads {
+		name, err := c.safePath(entry.Name)
+		if err != nil {
+			continue
+		}
+		current[strings.ToLower(manifestKey(name))] = true
+	}
+
+	// the state is saved at the end, after the prompt, so only the keys to drop are collected
+	dropped := []string{}
+	orphans := []string{}
+	for key, hash := range state.Installed {
+		if current[strings.ToLower(key)] {
+			continue
+		}
+		name, err := c.safePath(key)
+		if err != nil {
+			slog.Print("Dropping %s from the installed files: %s", key, err)
+			dropped = append(dropped, key)
+			continue
+		}
+		localHash, err := md5Checksum(name)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				slog.Print("Failed to check %s: %s", name, err)
+			}
+			dropped = append(dropped, key)
+			continue
+		}
+		if !strings.EqualFold(localHash, hash) || c.isProtected(name) {
+			slog.Debug("Keeping changed or protected file no longer in the patch", "file", name)
+			dropped = append(dropped, key)
+			continue
+		}
+		orphans = append(orphans, key)
+	}
+	sort.Strings(orphans)
+
+	if len(orphans) > 0 {
+		slog.Print("%d installed files are no longer part of the patch", len(orphans))
+		for _, key := range orphans {
+			slog.Debug("Orphaned file", "file", key)
+		}
+	}
+
+	isClean := false
+	switch c.cfg.Orphans {
+	case "auto":
+		isClean = true
+	case "prompt":
+		isClean = len(orphans) > 0 && gui.MessageBoxYesNo("Remove old files", fmt.Sprintf("%d files installed by an earlier patch are no longer used.\nRemove them?", len(orphans)))
+	}
+
+	if isClean {
+		summary := &deleteSummary{}
+		for _, key := range orphans {
+			name, err := c.safePath(key)
+			if err != nil {
+				continue
+			}
+			c.deletePath(name, summary)
+			dropped = append(dropped, key)
+		}
+		slog.Print("Removed %d old files, %d failed", summary.files, summary.failed)
+	}
+	return c.updateState(func(state *State) {
+		for _, key := range dropped {
+			delete(state.Installed, key)
+		}
+	})
+}
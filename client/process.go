@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+)
+
+const (
+	// gameStartupTime is how long eqgame.exe must stay running to count as started
+	gameStartupTime = 5 * time.Second
+)
+
+// crashDumpPatterns are globs, relative to the game path, where eqgame.exe leaves crash dumps
+var crashDumpPatterns = []string{"*.dmp", "crashes/*.dmp", "logs/*.dmp"}
+
+// gameProcess is a supervised eqgame.exe
+type gameProcess struct {
+	name     string
+	cmd      *exec.Cmd
+	start    time.Time
+	done     chan struct{}
+	exitCode int
+	err      error
+}
+
+// startGame starts cmd and waits until it either survives gameStartupTime or exits
+func (c *Client) startGame(name string, cmd *exec.Cmd) (*gameProcess, error) {
+	p := &gameProcess{
+		name: name,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	p.start = time.Now()
+	err := cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	go func() {
+		p.err = cmd.Wait()
+		p.exitCode = -1
+		if cmd.ProcessState != nil {
+			p.exitCode = cmd.ProcessState.ExitCode()
+		}
+		close(p.done)
+	}()
+
+	select {
+	case <-p.done:
+		slog.Error("EverQuest exited during startup", "name", p.name, "code", p.exitCode, "runtime", time.Since(p.start).Round(time.Millisecond))
+		return nil, fmt.Errorf("%s exited immediately with code %d", p.name, p.exitCode)
+	case <-time.After(gameStartupTime):
+	case <-c.ctx.Done():
+		return nil, fmt.Errorf("launcher closing")
+	}
+	slog.Info("EverQuest started", "name", p.name, "pid", cmd.Process.Pid)
+	c.gameWg.Add(1)
+	go c.superviseGame(p)
+	return p, nil
+}
+
+// superviseGame waits for p to exit, logs how it ended and offers a repair after a crash
+func (c *Client) superviseGame(p *gameProcess) {
+	defer c.gameWg.Done()
+	select {
+	case <-p.done:
+	case <-c.ctx.Done():
+		return
+	}
+	elapsed := time.Since(p.start).Round(time.Second)
+	dumps := findCrashDumps(c.currentPath, p.start)
+	if p.exitCode == 0 && len(dumps) == 0 {
+		slog.Info("EverQuest exited", "name", p.name, "code", p.exitCode, "runtime", elapsed)
+		return
+	}
+	slog.Error("EverQuest crashed", "name", p.name, "code", p.exitCode, "runtime", elapsed, "dumps", len(dumps))
+	for _, dump := range dumps {
+		slog.Error("Crash dump found", "path", dump)
+	}
+	slog.Dump(c.baseName + ".txt")
+
+	if !gui.MessageBoxYesNo("EverQuest crashed", fmt.Sprintf("EverQuest exited unexpectedly (code %d) after %s.\nRepair install? This verifies every patched file.", p.exitCode, elapsed)) {
+		return
+	}
+	err := c.Repair()
+	if err != nil {
+		slog.Print("Failed to repair: %s", err)
+	}
+}
+
+// findCrashDumps returns crash dumps under root modified after since
+func findCrashDumps(root string, since time.Time) []string {
+	dumps := []string{}
+	for _, pattern := range crashDumpPatterns {
+		matches, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			fi, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+			if fi.ModTime().Before(since) {
+				continue
+			}
+			dumps = append(dumps, match)
+		}
+	}
+	return dumps
+}
+
+// WaitGames blocks until every supervised eqgame.exe has exited and any
+// repair offered after a crash has finished
+func (c *Client) WaitGames() {
+	c.gameWg.Wait()
+}
+
+// Repair re-verifies every file in the filelist, even if the patch version is current
+func (c *Client) Repair() error {
+	if !c.patchMu.TryLock() {
+		slog.Print("Patch already in progress")
+		return fmt.Errorf("patch already in progress")
+	}
+	defer c.patchMu.Unlock()
+
+	slog.Print("Repairing install, all files will be verified")
+	version := c.cfg.Version
+	c.cfg.Version = ""
+	err := c.runPatch()
+	if err != nil {
+		c.cfg.Version = version
+		return fmt.Errorf("patch: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isProtected returns true if name matches a pattern in the protected config
+// list. Patterns are globs matched case insensitively against the slash
+// separated path, a pattern without a slash also matches the file name in any
+// folder, and a pattern matching a folder protects everything inside it
+func (c *Client) isProtected(name string) bool {
+	name = strings.ToLower(filepath.ToSlash(filepath.Clean(name)))
+	for _, pattern := range c.cfg.Protected {
+		pattern = strings.ToLower(strings.Trim(filepath.ToSlash(pattern), "/"))
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, "/") {
+			ok, _ := path.Match(pattern, path.Base(name))
+			if ok {
+				return true
+			}
+		}
+		for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+			ok, _ := path.Match(pattern, dir)
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// reservedNames are file names windows refuses to create in any folder
+var reservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// safePath validates name from a filelist against the game folder, see safeJoin
+func (c *Client) safePath(name string) (string, error) {
+	return safeJoin(c.currentPath, name)
+}
+
+// safeJoin validates name, a slash or backslash separated path from a filelist
+// or archive, and returns it as a clean path relative to root. Absolute paths,
+// drive letters, UNC paths, paths escaping root with .. or through a symlink,
+// and names windows reserves are rejected
+func safeJoin(root string, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	if len(name) >= 2 && name[1] == ':' {
+		return "", fmt.Errorf("%q has a drive letter", name)
+	}
+	if strings.ContainsAny(name, "\x00:<>\"|") {
+		return "", fmt.Errorf("%q has characters not allowed in a path", name)
+	}
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "//") {
+		return "", fmt.Errorf("%q is a UNC path", name)
+	}
+	if strings.HasPrefix(slashed, "/") {
+		return "", fmt.Errorf("%q is an absolute path", name)
+	}
+	clean := path.Clean(slashed)
+	if clean == "." {
+		return "", fmt.Errorf("%q is the root folder", name)
+	}
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%q is outside the game folder", name)
+	}
+	for _, segment := range strings.Split(clean, "/") {
+		base := strings.ToLower(segment)
+		if i := strings.Index(base, "."); i >= 0 {
+			base = base[:i]
+		}
+		if reservedNames[strings.TrimSpace(base)] {
+			return "", fmt.Errorf("%q uses the reserved name %s", name, segment)
+		}
+		if strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") {
+			return "", fmt.Errorf("%q has a name ending in a dot or space", name)
+		}
+		for _, r := range segment {
+			if r < 32 {
+				return "", fmt.Errorf("%q has control characters", name)
+			}
+		}
+	}
+
+	rel := filepath.FromSlash(clean)
+	err := checkSymlinks(root, rel)
+	if err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// checkSymlinks returns an error if any existing part of rel is a symlink
+// leading outside of root
+func checkSymlinks(root string, rel string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", root, err)
+	}
+	current := root
+	for _, segment := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, segment)
+		fi, err := os.Lstat(current)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("stat %s: %w", current, err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", current, err)
+		}
+		inside, err := filepath.Rel(realRoot, target)
+		if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s links outside the game folder", current)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xackery/starteq/gui"
+	"github.com/xackery/starteq/slog"
+	"github.com/xackery/starteq/torrent"
+)
+
+// seedStopTimeout is how long closing the launcher waits for seeding to stop
+const seedStopTimeout = 5 * time.Second
+
+// startSeeding shares everquest_rof2 in the background if seeding is enabled
+// and the client is installed. It runs until the launcher closes or a seed
+// target is reached, and only once per launch
+func (c *Client) startSeeding() {
+	if !c.cfg.Seed {
+		return
+	}
+	_, err := os.Stat("everquest_rof2")
+	if err != nil {
+		return
+	}
+	_, err = os.Stat("eqgame.exe")
+	if err != nil {
+		return
+	}
+
+	c.seedMu.Lock()
+	if c.seedDone != nil {
+		c.seedMu.Unlock()
+		return
+	}
+	done := make(chan struct{})
+	c.seedDone = done
+	c.seedMu.Unlock()
+
+	state, err := c.loadState()
+	if err != nil {
+		state = &State{}
+	}
+	opts := torrent.SeedOptions{
+		DataDir:     ".",
+		UploadLimit: c.cfg.SeedUploadLimit * 1024,
+		Ratio:       c.cfg.SeedRatio,
+		Duration:    time.Duration(c.cfg.SeedMinutes) * time.Minute,
+		Resume:      state.Torrent,
+		// data copied from an archive or another install has no saved progress,
+		// save it once verified so the next launch doesn't verify it again
+		OnProgress: c.saveTorrentProgress,
+		OnStatus: func(s torrent.SeedStatus) {
+			gui.SetStatus(fmt.Sprintf("Seeding to %d peers, %s uploaded, ratio %0.2f", s.Peers, generateSize(int(s.Uploaded)), s.Ratio))
+		},
+	}
+	go func() {
+		defer close(done)
+		defer gui.SetStatus("")
+		_, opts.Trackers = c.torrentSources()
+		err := c.torrenter.Seed(c.ctx, c.torrentData, opts)
+		if err != nil {
+			slog.Print("Failed to seed: %s", err)
+		}
+	}()
+}
+
+// stopSeeding waits for seeding to stop after the launcher context is done
+func (c *Client) stopSeeding() {
+	c.seedMu.Lock()
+	done := c.seedDone
+	c.seedMu.Unlock()
+	if done == nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(seedStopTimeout):
+		slog.Print("Timed out waiting for seeding to stop")
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/xackery/starteq/disk"
+	"github.com/xackery/starteq/slog"
+)
+
+// diskSpaceMargin is kept free on top of what a patch or download needs
+const diskSpaceMargin = 100 * 1024 * 1024
+
+// checkPatchSpace returns an error if the game folder's volume cannot hold the
+// pending downloads
+func (c *Client) checkPatchSpace(pending []FileEntry) error {
+	need := uint64(0)
+	for _, entry := range pending {
+		need += uint64(entry.Size)
+	}
+	if need == 0 {
+		return nil
+	}
+	return c.checkSpace(c.currentPath, need)
+}
+
+// checkSpace returns an error if path's volume has less than need bytes, plus
+// a margin, free. A failure to read free space is logged and ignored
+func (c *Client) checkSpace(path string, need uint64) error {
+	err := disk.Check(path, need, diskSpaceMargin)
+	if err == nil {
+		return nil
+	}
+	_, isSpace := err.(*disk.SpaceError)
+	if !isSpace {
+		slog.Print("Failed to check free disk space, continuing: %s", err)
+		return nil
+	}
+	slog.Print("%s", err)
+	return err
+}
+
+// dirSize returns the total size of files under path, 0 if it does not exist
+func dirSize(path string) uint64 {
+	total := uint64(0)
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xackery/starteq/torrent"
+	"gopkg.in/yaml.v3"
+)
+
+// stateMaxHistory is how many applied patches are remembered
+const stateMaxHistory = 50
+
+// State is what the launcher remembers about the install between runs, kept
+// in <baseName>-state.yml next to the ini
+type State struct {
+	History   []PatchRecord     `yaml:"history"`
+	Installed map[string]string `yaml:"installed"` // files the launcher downloaded, slash separated name to md5
+	Bundles   map[string]string `yaml:"bundles"`   // bundle name to the version, or md5, last extracted
+	Torrent   *torrent.Progress `yaml:"torrent"`   // last known progress of the everquest_rof2 torrent
+}
+
+// PatchRecord is a filelist version that was applied
+type PatchRecord struct {
+	Version string    `yaml:"version"`
+	Channel string    `yaml:"channel"`
+	Applied time.Time `yaml:"applied"`
+}
+
+func (c *Client) statePath() string {
+	return c.baseName + "-state.yml"
+}
+
+// loadState reads the state file, returning an empty state if it does not exist
+func (c *Client) loadState() (*State, error) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.readState()
+}
+
+// updateState loads the state file, lets fn change it and saves it, holding
+// stateMu throughout so concurrent updates don't drop each other's changes
+func (c *Client) updateState(fn func(state *State)) error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	state, err := c.readState()
+	if err != nil {
+		return err
+	}
+	fn(state)
+	return c.writeState(state)
+}
+
+// readState reads the state file, the caller must hold stateMu
+func (c *Client) readState() (*State, error) {
+	state := &State{}
+	data, err := os.ReadFile(c.statePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", c.statePath(), err)
+	}
+	err = yaml.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", c.statePath(), err)
+	}
+	return state, nil
+}
+
+// writeState writes the state file, the caller must hold stateMu
+func (c *Client) writeState(state *State) error {
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	err = os.WriteFile(c.statePath(), data, 0644)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", c.statePath(), err)
+	}
+	return nil
+}
+
+// recordPatch adds version to the patch history
+func (c *Client) recordPatch(version string) error {
+	return c.updateState(func(state *State) {
+		state.History = append(state.History, PatchRecord{
+			Version: version,
+			Channel: c.cfg.Channel,
+			Applied: time.Now().UTC(),
+		})
+		if len(state.History) > stateMaxHistory {
+			state.History = state.History[len(state.History)-stateMaxHistory:]
+		}
+	})
+}
@@ -1,22 +1,61 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"fmt"
 	"time"
 
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/xackery/starteq/slog"
 	"github.com/xackery/starteq/torrent"
 )
 
 //go:embed rof2.torrent
 var torrentContent []byte
 
+// torrentPeerTimeout is how long the torrent may go without peers or progress before giving up
+const torrentPeerTimeout = 3 * time.Minute
+
 // Torrent downloads the torrent
 func (c *Client) Torrent(ctx context.Context) error {
 	start := time.Now()
-	m := torrent.Torrent{}
-	err := m.Download(ctx, torrentContent)
+	mi, err := metainfo.Load(bytes.NewReader(c.torrentData))
+	if err != nil {
+		return fmt.Errorf("metainfo load: %w", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return fmt.Errorf("metainfo info: %w", err)
+	}
+	// the download is staged in everquest_rof2, then copied into the game folder
+	total := uint64(info.TotalLength())
+	remaining := uint64(0)
+	existing := dirSize("everquest_rof2")
+	if existing < total {
+		remaining = total - existing
+	}
+	err = c.checkSpace(c.currentPath, remaining+total)
+	if err != nil {
+		return err
+	}
+
+	state, err := c.loadState()
+	if err != nil {
+		slog.Print("Failed to load torrent progress, existing data will be verified: %s", err)
+		state = &State{}
+	}
+
+	webSeeds, trackers := c.torrentSources()
+	err = c.torrenter.Download(ctx, c.torrentData, torrent.Options{
+		DataDir:     ".",
+		PeerTimeout: torrentPeerTimeout,
+		Resume:      state.Torrent,
+		OnProgress:  c.saveTorrentProgress,
+		WebSeeds:    webSeeds,
+		Trackers:    trackers,
+	})
 	if err != nil {
 		return fmt.Errorf("download: %w", err)
 	}
@@ -24,8 +63,33 @@ func (c *Client) Torrent(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("copyBackup: %w", err)
 	}
+	c.startSeeding()
 
 	fmt.Printf("Finished in %0.2f seconds\n", time.Since(start).Seconds())
 
 	return nil
 }
+
+// saveTorrentProgress remembers how far the torrent got for the next launch
+func (c *Client) saveTorrentProgress(p torrent.Progress) {
+	err := c.updateState(func(state *State) {
+		state.Torrent = &p
+	})
+	if err != nil {
+		slog.Debug("Failed to save torrent progress", "error", err)
+	}
+}
+
+// torrentSources returns the web seeds and trackers from the ini and the server
+func (c *Client) torrentSources() ([]string, []string) {
+	settings := c.serverSettings()
+	c.settingsMu.Lock()
+	webSeeds := append([]string{}, c.cfg.WebSeeds...)
+	trackers := append([]string{}, c.cfg.Trackers...)
+	c.settingsMu.Unlock()
+	if settings != nil {
+		webSeeds = append(webSeeds, settings.WebSeeds...)
+		trackers = append(trackers, settings.Trackers...)
+	}
+	return webSeeds, trackers
+}
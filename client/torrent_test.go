@@ -0,0 +1,229 @@
+package client
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/xackery/starteq/config"
+	"github.com/xackery/starteq/torrent"
+)
+
+var testGame = []byte("eqgame")
+
+// newTestClient returns a client in an empty game folder that downloads
+// everquest_rof2 with torrenter. The working folder is restored after the test
+func newTestClient(t *testing.T, torrenter torrent.Torrenter, patcherURL string) *Client {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %s", err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatalf("chdir: %s", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg, err := config.New(ctx, "starteq")
+	if err != nil {
+		t.Fatalf("config: %s", err)
+	}
+	cfg.IsTorrentOK = true
+	c, err := New(ctx, cancel, cfg, "dev", patcherURL, WithTorrenter(torrenter), WithTorrentData(testTorrent(t)))
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+	c.patchCtx = ctx
+	t.Cleanup(func() { c.Done() })
+	return c
+}
+
+// testTorrent returns a torrent of everquest_rof2 holding only eqgame.exe
+func testTorrent(t *testing.T) []byte {
+	t.Helper()
+	info := metainfo.Info{
+		Name:        "everquest_rof2",
+		PieceLength: 16384,
+		Pieces:      make([]byte, 20),
+		Files:       []metainfo.FileInfo{{Path: []string{"eqgame.exe"}, Length: int64(len(testGame))}},
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatalf("marshal info: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	err = (&metainfo.MetaInfo{InfoBytes: infoBytes}).Write(buf)
+	if err != nil {
+		t.Fatalf("write torrent: %s", err)
+	}
+	return buf.Bytes()
+}
+
+// newPatchServer serves rof2.zip holding eqgame.exe and 404s anything else
+func newPatchServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create("eqgame.exe")
+	if err != nil {
+		t.Fatalf("zip create: %s", err)
+	}
+	w.Write(testGame)
+	err = zw.Close()
+	if err != nil {
+		t.Fatalf("zip close: %s", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rof2.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func assertGameInstalled(t *testing.T) {
+	t.Helper()
+	data, err := os.ReadFile("eqgame.exe")
+	if err != nil {
+		t.Fatalf("eqgame.exe was not copied into the game folder: %s", err)
+	}
+	if !bytes.Equal(data, testGame) {
+		t.Fatalf("eqgame.exe is %q, want %q", data, testGame)
+	}
+}
+
+func TestPrePatchTorrent(t *testing.T) {
+	srv := newPatchServer(t)
+	mock := &torrent.Mock{
+		Steps: 2,
+		Total: int64(len(testGame)),
+		Files: map[string][]byte{"everquest_rof2/eqgame.exe": testGame},
+	}
+	c := newTestClient(t, mock, srv.URL)
+	c.cfg.BaseSource = "torrent"
+
+	err := c.PrePatch()
+	if err != nil {
+		t.Fatalf("prepatch: %s", err)
+	}
+	if mock.Downloads() != 1 {
+		t.Fatalf("torrent downloaded %d times, want 1", mock.Downloads())
+	}
+	_, err = os.Stat(filepath.Join("everquest_rof2", "eqgame.exe"))
+	if err != nil {
+		t.Fatalf("everquest_rof2 was not kept: %s", err)
+	}
+	assertGameInstalled(t)
+}
+
+func TestPrePatchArchiveFallback(t *testing.T) {
+	srv := newPatchServer(t)
+	mock := &torrent.Mock{Err: errors.New("no peers")}
+	c := newTestClient(t, mock, srv.URL)
+	c.cfg.BaseSource = "auto"
+	c.cfg.ArchiveURLs = []string{srv.URL + "/rof2.zip"}
+
+	err := c.PrePatch()
+	if err != nil {
+		t.Fatalf("prepatch: %s", err)
+	}
+	if mock.Downloads() != 1 {
+		t.Fatalf("torrent downloaded %d times, want 1", mock.Downloads())
+	}
+	assertGameInstalled(t)
+}
+
+func TestPrePatchTorrentFailed(t *testing.T) {
+	srv := newPatchServer(t)
+	mock := &torrent.Mock{Err: errors.New("no peers")}
+	c := newTestClient(t, mock, srv.URL)
+	c.cfg.BaseSource = "torrent"
+	c.cfg.ArchiveURLs = []string{srv.URL + "/rof2.zip"}
+
+	err := c.PrePatch()
+	if err == nil {
+		t.Fatalf("prepatch succeeded with a failed torrent and source torrent")
+	}
+	_, err = os.Stat("eqgame.exe")
+	if !os.IsNotExist(err) {
+		t.Fatalf("eqgame.exe exists after a failed torrent: %v", err)
+	}
+}
+
+func TestTorrentCancel(t *testing.T) {
+	srv := newPatchServer(t)
+	mock := &torrent.Mock{
+		Steps: 100,
+		Delay: 10 * time.Millisecond,
+		Total: 100,
+		Files: map[string][]byte{"everquest_rof2/eqgame.exe": testGame},
+	}
+	c := newTestClient(t, mock, srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	err := c.Torrent(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("torrent returned %v, want context.Canceled", err)
+	}
+	if len(mock.Progress()) >= mock.Steps {
+		t.Fatalf("torrent finished all %d steps after being cancelled", mock.Steps)
+	}
+	_, err = os.Stat("eqgame.exe")
+	if !os.IsNotExist(err) {
+		t.Fatalf("eqgame.exe exists after a cancelled torrent: %v", err)
+	}
+
+	// progress made before cancelling is kept for the next launch
+	state, err := c.loadState()
+	if err != nil {
+		t.Fatalf("load state: %s", err)
+	}
+	if state.Torrent == nil || state.Torrent.Completed == 0 {
+		t.Fatalf("no torrent progress saved before cancelling: %+v", state.Torrent)
+	}
+}
+
+func TestTorrentProgressSaved(t *testing.T) {
+	srv := newPatchServer(t)
+	mock := &torrent.Mock{
+		Steps: 4,
+		Total: 400,
+		Files: map[string][]byte{"everquest_rof2/eqgame.exe": testGame},
+	}
+	c := newTestClient(t, mock, srv.URL)
+
+	err := c.Torrent(context.Background())
+	if err != nil {
+		t.Fatalf("torrent: %s", err)
+	}
+	progress := mock.Progress()
+	if len(progress) != mock.Steps {
+		t.Fatalf("got %d progress reports, want %d", len(progress), mock.Steps)
+	}
+	state, err := c.loadState()
+	if err != nil {
+		t.Fatalf("load state: %s", err)
+	}
+	if state.Torrent == nil {
+		t.Fatalf("torrent progress was not saved")
+	}
+	if state.Torrent.Completed != 400 || state.Torrent.Total != 400 {
+		t.Fatalf("saved progress %d/%d, want 400/400", state.Torrent.Completed, state.Torrent.Total)
+	}
+	assertGameInstalled(t)
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fynelabs/selfupdate"
+	"github.com/xackery/starteq/slog"
+)
+
+const (
+	// updateConfirmTimeout is how long an updated launcher has to confirm it
+	// started, which includes an auto patch run before its window shows
+	updateConfirmTimeout = 2 * time.Minute
+	// updateConfirmEnv is set on an updated launcher to the marker file it confirms startup in
+	updateConfirmEnv = "STARTEQ_UPDATE_CONFIRM"
+)
+
+// errRestarting is returned when the launcher is restarting into an updated executable
+var errRestarting = fmt.Errorf("restarting to apply launcher update")
+
+// isLauncherOutdated returns true if the server requires a newer launcher than this one
+func (c *Client) isLauncherOutdated() bool {
+	settings := c.loadedSettings()
+	if settings == nil || settings.MinLauncherVersion == "" {
+		return false
+	}
+	current, err := parseVersion(c.version)
+	if err != nil {
+		slog.Print("Launcher version %s is not a release, skipping minimum version %s check", c.version, settings.MinLauncherVersion)
+		return false
+	}
+	min, err := parseVersion(settings.MinLauncherVersion)
+	if err != nil {
+		return false
+	}
+	return compareVersions(current, min) < 0
+}
+
+// forceSelfUpdate updates the launcher and restarts it. It always returns an
+// error, errRestarting when the new launcher took over
+func (c *Client) forceSelfUpdate() error {
+	min := c.loadedSettings().MinLauncherVersion
+	slog.Print("%s %s is older than %s required by the server, updating before patching", c.baseName, c.version, min)
+	isUpdated, err := c.selfUpdate()
+	if err != nil {
+		return fmt.Errorf("required self update: %w", err)
+	}
+	if !isUpdated {
+		return fmt.Errorf("%s %s is below the minimum %s and no update is available", c.baseName, c.version, min)
+	}
+	return errRestarting
+}
+
+// updateMarkerPath returns the file the old and new launcher use to agree on
+// whether an update started cleanly
+func (c *Client) updateMarkerPath() string {
+	return filepath.Join(c.currentPath, "."+c.baseName+".update")
+}
+
+// oldExePath returns where the previous launcher executable is kept for rollback
+func (c *Client) oldExePath(exeName string) string {
+	return filepath.Join(filepath.Dir(exeName), "."+c.baseName+".exe.old")
+}
+
+// isUpdateFailed returns true if hash is a release that already failed to start
+func (c *Client) isUpdateFailed(hash string) bool {
+	data, err := os.ReadFile(c.updateMarkerPath())
+	if err != nil {
+		return false
+	}
+	state := strings.Fields(string(data))
+	return len(state) == 2 && state[0] == "failed" && strings.EqualFold(state[1], hash)
+}
+
+// ConfirmUpdate tells a launcher waiting on this one after a self update that
+// it started cleanly. It is called once the window shows, or after a clean auto
+// play that never shows it. It does nothing if this launcher was not started by an update
+func (c *Client) ConfirmUpdate() {
+	path := os.Getenv(updateConfirmEnv)
+	if path == "" {
+		return
+	}
+	os.Unsetenv(updateConfirmEnv)
+	err := os.WriteFile(path, []byte("ok"), 0644)
+	if err != nil {
+		slog.Print("Failed to confirm update: %s", err)
+		return
+	}
+	slog.Print("Updated to %s %s", c.baseName, c.version)
+}
+
+// restartUpdated starts the freshly applied launcher and waits for it to confirm
+// a clean startup. On success this launcher closes. If the new launcher exits
+// or does not confirm in time it is stopped, the previous executable is put
+// back and an error is returned so this launcher carries on
+func (c *Client) restartUpdated(exeName string, hash string) error {
+	marker := c.updateMarkerPath()
+	err := os.WriteFile(marker, []byte("pending "+hash), 0644)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", marker, err)
+	}
+
+	slog.Print("Restarting %s", c.baseName)
+	slog.Dump(c.baseName + ".txt")
+	cmd := exec.Command(exeName, os.Args[1:]...)
+	cmd.Dir = c.currentPath
+	cmd.Env = append(os.Environ(), updateConfirmEnv+"="+marker)
+	err = cmd.Start()
+	if err != nil {
+		return c.rollbackUpdate(exeName, hash, fmt.Errorf("start %s: %w", exeName, err))
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(updateConfirmTimeout)
+	for {
+		select {
+		case err = <-exited:
+			if err == nil {
+				err = fmt.Errorf("exited before confirming startup")
+			}
+			return c.rollbackUpdate(exeName, hash, fmt.Errorf("new launcher: %w", err))
+		case <-timeout:
+			cmd.Process.Kill()
+			<-exited
+			return c.rollbackUpdate(exeName, hash, fmt.Errorf("new launcher did not confirm startup within %s", updateConfirmTimeout))
+		case <-ticker.C:
+			data, err := os.ReadFile(marker)
+			if err != nil || strings.TrimSpace(string(data)) != "ok" {
+				continue
+			}
+			os.Remove(marker)
+			slog.Print("New %s started, closing this one", c.baseName)
+			c.cancel()
+			return nil
+		}
+	}
+}
+
+// rollbackUpdate puts the previous launcher executable back after a failed
+// update and remembers hash so the same release is not applied again
+func (c *Client) rollbackUpdate(exeName string, hash string, cause error) error {
+	slog.Print("Update failed, rolling back: %s", cause)
+	err := os.WriteFile(c.updateMarkerPath(), []byte("failed "+hash), 0644)
+	if err != nil {
+		slog.Print("Failed to record failed update: %s", err)
+	}
+	oldPath := c.oldExePath(exeName)
+	err = os.Remove(exeName)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("%s (remove new executable: %w)", cause, err)
+	}
+	err = os.Rename(oldPath, exeName)
+	if err != nil {
+		return fmt.Errorf("%s (restore %s: %w)", cause, oldPath, err)
+	}
+	return fmt.Errorf("%w, rolled back to %s", cause, c.version)
+}
+
+// selfUpdate replaces the launcher executable if the server has a different
+// build and restarts into it, returning true if the new launcher took over
+func (c *Client) selfUpdate() (bool, error) {
+	client := c.downloadClient
+
+	exeName, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("executable: %w", err)
+	}
+
+	baseName := c.baseName
+
+	err = os.Remove(baseName + ".bat")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Print("Failed to remove %s.bat: %s", baseName, err)
+		}
+	} else {
+		slog.Print("Removed %s.bat", baseName)
+	}
+
+	oldPath := c.oldExePath(exeName)
+	err = os.Remove(oldPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Print("Failed to remove %s: %s", filepath.Base(oldPath), err)
+		}
+	} else {
+		slog.Print("Removed %s", filepath.Base(oldPath))
+	}
+
+	myHash, err := md5Checksum(exeName)
+	if err != nil {
+		return false, fmt.Errorf("checksum: %w", err)
+	}
+	slog.Print("Checking for self update on the %s channel", c.cfg.Channel)
+	resp, prefix, err := c.getChannelFile("starteq-hash.txt")
+	if err != nil {
+		return false, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return false, fmt.Errorf("read %s/starteq-hash.txt: %w", prefix, err)
+	}
+
+	remoteHash := strings.TrimSpace(string(data))
+
+	if remoteHash == "Not Found" {
+		slog.Print("Remote site down, ignoring self update")
+		return false, nil
+	}
+
+	if strings.EqualFold(myHash, remoteHash) {
+		slog.Print("Self update not needed")
+		return false, nil
+	}
+
+	if c.isUpdateFailed(remoteHash) {
+		slog.Print("Skipping self update, %s failed to start last time", remoteHash)
+		return false, nil
+	}
+
+	slog.Print("Updating %s... %s vs %s", c.baseName, myHash, remoteHash)
+
+	url := fmt.Sprintf("%s/%s.exe", prefix, c.baseName)
+	slog.Print("Downloading %s at %s", c.baseName, url)
+	resp, err = client.Get(url)
+	if err != nil {
+		return false, fmt.Errorf("get: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return false, fmt.Errorf("download %s responded %d (not 200)", url, resp.StatusCode)
+	}
+	slog.Print("Applying update")
+	err = selfupdate.Apply(resp.Body, selfupdate.Options{OldSavePath: oldPath})
+	if err != nil {
+		return false, fmt.Errorf("apply: %w", err)
+	}
+
+	err = c.restartUpdated(exeName, remoteHash)
+	if err != nil {
+		return false, fmt.Errorf("restart: %w", err)
+	}
+	return true, nil
+}
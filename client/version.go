@@ -0,0 +1,46 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseVersion splits a dotted version like 0.0.9.123 into its numbers
+func parseVersion(value string) ([]int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if value == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+	parts := strings.Split(value, ".")
+	out := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("version %q is not numeric", value)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// compareVersions returns -1, 0 or 1 if a is older, equal or newer than b.
+// Missing trailing numbers count as 0, so 1.2 equals 1.2.0
+func compareVersions(a []int, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := 0, 0
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
@@ -1,247 +1,311 @@
 package config
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"os"
+	"sort"
 	"strings"
+
+	"github.com/xackery/starteq/slog"
 )
 
 // Config represents a configuration parse
 type Config struct {
-	Version     string
-	baseName    string
-	IsAutoPlay  bool
-	IsAutoPatch bool
-	IsTorrentOK bool
+	Version         string
+	baseName        string
+	IsAutoPlay      bool
+	IsAutoPatch     bool
+	IsTorrentOK     bool
+	LogLevel        string
+	Channel         string                 // release channel, stable or beta, for launcher and game updates
+	PinVersion      string                 // filelist version to stay on, empty follows the latest
+	LaunchDelay     int                    // seconds to wait between launching accounts
+	Profiles        map[string][]string    // launch profile name to account names
+	LaunchExe       string                 // optional wrapper, eqgame.exe and its arguments are passed to it
+	LaunchArgs      string                 // extra arguments appended to eqgame.exe
+	LaunchEnv       []string               // extra KEY=VALUE environment variables
+	LaunchDir       string                 // working directory, defaults to the launcher path
+	PreLaunch       string                 // command run before each launch, a failure aborts the launch
+	PostExit        string                 // command run after each eqgame.exe exits
+	WineRunner      string                 // wine, wine64 or proton command used to run eqgame.exe outside of windows
+	WinePrefix      string                 // WINEPREFIX (or proton compatdata path) to run eqgame.exe in
+	LoginHost       string                 // host:port written to eqhost.txt, empty leaves eqhost.txt alone
+	MaxDownloads    int                    // files downloaded at once while patching
+	Mirrors         []string               // extra download prefixes tried when the filelist's fails
+	Protected       []string               // glob patterns of files patching never overwrites or deletes
+	Orphans         string                 // off, prompt or auto removal of installed files dropped from the filelist
+	Seed            bool                   // share everquest_rof2 over the torrent while the launcher is open
+	SeedUploadLimit int                    // KB per second, 0 is unlimited
+	SeedRatio       float64                // stop seeding after uploading this many times the client size, 0 has no target
+	SeedMinutes     int                    // stop seeding after this long, 0 seeds until the launcher closes
+	WebSeeds        []string               // extra http(s) sources added to the torrent
+	Trackers        []string               // extra trackers added to the torrent
+	BaseSource      string                 // auto, torrent or http, where a missing everquest_rof2 is downloaded from
+	ArchiveURLs     []string               // http(s) zip of the client, several urls are parts joined in order
+	ArchiveSize     int                    // size of a single part archive, 0 if unknown
+	ArchiveMd5      string                 // md5 of the whole archive, optional
+	local           map[string]bool        // fields present in the local ini
+	remote          map[string]remoteValue // fields set by ApplyRemote
+	configVersion   int
+	problems        []string        // values that failed to parse on load
+	invalid         map[string]bool // fields that failed to parse, left untouched on save
+}
+
+// ValidationError lists every problem found by Verify
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+	return fmt.Sprintf("%d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
 }
 
 // New creates a new configuration
 func New(ctx context.Context, baseName string) (*Config, error) {
-	var f *os.File
 	cfg := &Config{
 		baseName: baseName,
 	}
+	cfg.setDefaults()
 	path := baseName + ".ini"
 
-	isNewConfig := false
 	fi, err := os.Stat(path)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, fmt.Errorf("config info: %w", err)
 		}
-		f, err = os.Create(path)
-		if err != nil {
-			return nil, fmt.Errorf("create %s.ini: %w", baseName, err)
-		}
-		fi, err = os.Stat(path)
-		if err != nil {
-			return nil, fmt.Errorf("new config info: %w", err)
-		}
-		isNewConfig = true
-	}
-	if !isNewConfig {
-		f, err = os.Open(path)
-		if err != nil {
-			return nil, fmt.Errorf("open config: %w", err)
-		}
-	}
-
-	defer f.Close()
-	if fi.IsDir() {
-		return nil, fmt.Errorf("%s.ini is a directory, should be a file", baseName)
-	}
-
-	if isNewConfig {
-		cfg = &Config{
-			baseName: baseName,
-		}
 		err = cfg.Save()
 		if err != nil {
 			return nil, fmt.Errorf("save config: %w", err)
 		}
 		return cfg, nil
 	}
+	if fi.IsDir() {
+		return nil, fmt.Errorf("%s.ini is a directory, should be a file", baseName)
+	}
 
-	err = decode(f, cfg)
+	doc, err := cfg.load()
 	if err != nil {
 		return nil, fmt.Errorf("decode %s.ini: %w", baseName, err)
 	}
-
+	err = cfg.migrate(doc)
+	if err != nil {
+		return nil, fmt.Errorf("migrate %s.ini: %w", baseName, err)
+	}
+	cfg.decode(doc)
 	return cfg, nil
 }
 
-// Verify returns an error if configuration appears off
+// Verify returns a ValidationError listing every setting that appears off
 func (c *Config) Verify() error {
-
-	return nil
-}
-
-func decode(r io.Reader, cfg *Config) error {
-	reader := bufio.NewScanner(r)
-	for reader.Scan() {
-		line := reader.Text()
-		if strings.HasPrefix(line, "#") {
+	problems := append([]string{}, c.problems...)
+	for i := range fields {
+		f := &fields[i]
+		if f.validate == nil || c.invalid[f.name()] {
 			continue
 		}
-		if !strings.Contains(line, "=") {
-			continue
+		err := f.validate(c, f.get(c))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", f.name(), err))
 		}
-		parts := strings.Split(line, "=")
-		if len(parts) != 2 {
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// setDefaults sets every field to its declared default
+func (c *Config) setDefaults() {
+	c.Profiles = map[string][]string{}
+	c.invalid = map[string]bool{}
+	c.local = map[string]bool{}
+	c.remote = map[string]remoteValue{}
+	for i := range fields {
+		f := &fields[i]
+		if f.kind == kindMap {
 			continue
 		}
-		key := strings.ToLower(strings.TrimSpace(parts[0]))
-		value := strings.TrimSpace(parts[1])
-		switch key {
-		case "version":
-			cfg.Version = value
-		case "auto_patch":
-			if strings.ToLower(value) == "true" {
-				cfg.IsAutoPatch = true
-			}
-			if value == "1" {
-				cfg.IsAutoPatch = true
-			}
-		case "auto_play":
-			if strings.ToLower(value) == "true" {
-				cfg.IsAutoPlay = true
-			}
-			if value == "1" {
-				cfg.IsAutoPlay = true
-			}
-		case "torrent_ok":
-			if strings.ToLower(value) == "true" {
-				cfg.IsTorrentOK = true
-			}
-			if value == "1" {
-				cfg.IsTorrentOK = true
-			}
-
+		err := f.set(c, f.def)
+		if err != nil {
+			panic(fmt.Sprintf("config default %s: %s", f.name(), err))
 		}
 	}
-	return nil
 }
 
-// Save saves the config
-func (c *Config) Save() error {
-
-	fi, err := os.Stat(c.baseName + ".ini")
+// load parses the ini file, returning an empty document if it does not exist
+func (c *Config) load() (*document, error) {
+	f, err := os.Open(c.baseName + ".ini")
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return fmt.Errorf("stat %s.ini:  %w", c.baseName, err)
+		if os.IsNotExist(err) {
+			return &document{}, nil
 		}
-		w, err := os.Create(c.baseName + ".ini")
-		if err != nil {
-			return fmt.Errorf("create %s.ini: %w", c.baseName, err)
-		}
-		w.Close()
-	}
-	if fi != nil && fi.IsDir() {
-		return fmt.Errorf("dirCheck %s.ini: is a directory", c.baseName)
+		return nil, fmt.Errorf("open: %w", err)
 	}
-
-	r, err := os.Open(c.baseName + ".ini")
+	defer f.Close()
+	doc, err := parseDocument(f)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("parse: %w", err)
 	}
-	defer r.Close()
-
-	tmpConfig := &Config{}
+	return doc, nil
+}
 
-	out := ""
-	reader := bufio.NewScanner(r)
-	for reader.Scan() {
-		line := reader.Text()
-		if strings.HasPrefix(line, "#") {
-			out += line + "\n"
+func (c *Config) decode(doc *document) {
+	known := map[string]bool{}
+	for i := range fields {
+		f := &fields[i]
+		if f.kind == kindMap {
+			m := f.ptr(c).(*map[string][]string)
+			for _, key := range doc.keys(f.section, f.key) {
+				known[f.section+"."+key] = true
+				name := strings.TrimPrefix(key, f.key)
+				if name == "" {
+					continue
+				}
+				value, _ := doc.get(f.section, key)
+				(*m)[name] = splitList(value)
+			}
 			continue
 		}
-		if !strings.Contains(line, "=") {
-			out += line + "\n"
+		if f.kind == kindEnv {
+			env := f.ptr(c).(*[]string)
+			for _, l := range doc.lines {
+				if l.key == "" || l.section != f.section || !strings.HasPrefix(l.key, f.key) {
+					continue
+				}
+				known[l.section+"."+l.key] = true
+				name := strings.TrimSpace(l.name[len(f.key):])
+				if name == "" {
+					continue
+				}
+				*env = append(*env, name+"="+l.value)
+			}
 			continue
 		}
-		parts := strings.Split(line, "=")
-		if len(parts) != 2 {
+		known[f.section+"."+f.key] = true
+		value, ok := doc.get(f.section, f.key)
+		if !ok {
 			continue
 		}
+		c.local[f.name()] = true
+		err := f.set(c, value)
+		if err != nil {
+			c.problems = append(c.problems, fmt.Sprintf("%s: %s", f.name(), err))
+			c.invalid[f.name()] = true
+		}
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		switch key {
-		case "version":
-			if tmpConfig.Version == "1" {
-				continue
-			}
-			out += fmt.Sprintf("%s = %s\n", key, c.Version)
-			tmpConfig.Version = "1"
+	for _, l := range doc.lines {
+		if l.key == "" || known[l.section+"."+l.key] {
 			continue
-		case "auto_patch":
-			if tmpConfig.IsAutoPatch {
-				continue
-			}
+		}
+		slog.Warn("Unknown config key", "file", c.baseName+".ini", "section", l.section, "key", l.name, "line", l.number)
+	}
+}
 
-			if c.IsAutoPatch {
-				value = "true"
-			} else {
-				value = "false"
+func (c *Config) encode(doc *document) {
+	for i := range fields {
+		f := &fields[i]
+		if f.kind == kindMap {
+			m := *f.ptr(c).(*map[string][]string)
+			for _, key := range doc.keys(f.section, f.key) {
+				_, ok := m[strings.TrimPrefix(key, f.key)]
+				if !ok {
+					doc.remove(f.section, key)
+				}
 			}
-			tmpConfig.IsAutoPatch = true
-		case "auto_play":
-			if tmpConfig.IsAutoPlay {
-				continue
+			names := []string{}
+			for name := range m {
+				names = append(names, name)
 			}
-			if c.IsAutoPlay {
-				value = "true"
-			} else {
-				value = "false"
+			sort.Strings(names)
+			for _, name := range names {
+				doc.set(f.section, f.key+name, strings.Join(m[name], ", "))
 			}
-			tmpConfig.IsAutoPlay = true
-		case "torrent_ok":
-			if tmpConfig.IsTorrentOK {
-				continue
+			continue
+		}
+		if f.kind == kindEnv {
+			values := map[string]string{}
+			for _, env := range *f.ptr(c).(*[]string) {
+				parts := strings.SplitN(env, "=", 2)
+				if len(parts) == 2 {
+					values[strings.ToLower(f.key+parts[0])] = parts[1]
+				}
+			}
+			for _, key := range doc.keys(f.section, f.key) {
+				_, ok := values[key]
+				if !ok {
+					doc.remove(f.section, key)
+				}
+			}
+			for _, env := range *f.ptr(c).(*[]string) {
+				parts := strings.SplitN(env, "=", 2)
+				if len(parts) == 2 {
+					doc.set(f.section, f.key+parts[0], parts[1])
+				}
 			}
-			if c.IsTorrentOK {
-				value = "true"
-			} else {
-				value = "false"
+			continue
+		}
+		if c.invalid[f.name()] {
+			continue
+		}
+		value := f.get(c)
+		rv, isRemote := c.remote[f.name()]
+		if isRemote && value == rv.value {
+			// keep server provided values out of the local ini
+			if !rv.isLocal {
+				continue
 			}
-			tmpConfig.IsTorrentOK = true
+			value = rv.local
 		}
-		line = fmt.Sprintf("%s = %s", key, value)
-		out += line + "\n"
-	}
-
-	if tmpConfig.Version != "1" && c.Version != "" {
-		out += fmt.Sprintf("version = %s\n", c.Version)
-	}
-	if !tmpConfig.IsAutoPatch {
-		if c.IsAutoPatch {
-			out += "auto_patch = true\n"
-		} else {
-			out += "auto_patch = false\n"
+		_, ok := doc.get(f.section, f.key)
+		if !ok && !f.isAlways && value == f.def {
+			continue
 		}
+		doc.set(f.section, f.key, value)
 	}
-	if !tmpConfig.IsAutoPlay {
-		if c.IsAutoPlay {
-			out += "auto_play = true\n"
-		} else {
-			out += "auto_play = false\n"
-		}
+}
+
+// Save saves the config, keeping comments and unknown keys already in the file
+func (c *Config) Save() error {
+	fi, err := os.Stat(c.baseName + ".ini")
+	if err == nil && fi.IsDir() {
+		return fmt.Errorf("dirCheck %s.ini: is a directory", c.baseName)
 	}
-	if !tmpConfig.IsTorrentOK {
-		if c.IsTorrentOK {
-			out += "torrent_ok = true\n"
-		}
-		// no need to flag torrent ok if false
+
+	doc, err := c.load()
+	if err != nil {
+		return fmt.Errorf("load %s.ini: %w", c.baseName, err)
 	}
+	c.encode(doc)
+	return c.write(doc)
+}
 
-	err = os.WriteFile(c.baseName+".ini", []byte(out), 0644)
+// write replaces the ini file with doc
+func (c *Config) write(doc *document) error {
+	buf := &bytes.Buffer{}
+	err := doc.write(buf)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	err = os.WriteFile(c.baseName+".ini", buf.Bytes(), 0644)
 	if err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
-
 	return nil
 }
+
+// ProfileNames returns the launch profile names, sorted
+func (c *Config) ProfileNames() []string {
+	names := []string{}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// document is a parsed ini file. Comments, blank lines and unknown keys are
+// kept so a document can be written back with only the changed values differing
+type document struct {
+	lines []*line
+}
+
+// line is a single line of an ini file
+type line struct {
+	raw       string // original text, used for comments, blanks and section headers
+	number    int    // 1 based line number in the source, 0 for added lines
+	section   string // lowercase section the line belongs to, "" for top level
+	key       string // lowercase key, "" if the line is not a key
+	name      string // key as written in the file
+	value     string
+	isSection bool
+}
+
+func parseDocument(r io.Reader) (*document, error) {
+	doc := &document{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		l := &line{raw: text, number: number, section: section}
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = strings.ToLower(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			l.section = section
+			l.isSection = true
+		case strings.Contains(trimmed, "="):
+			parts := strings.SplitN(trimmed, "=", 2)
+			l.name = strings.TrimSpace(parts[0])
+			l.key = strings.ToLower(l.name)
+			l.value = unquote(strings.TrimSpace(parts[1]))
+		}
+		doc.lines = append(doc.lines, l)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+	return doc, nil
+}
+
+// get returns the first value of key in section
+func (d *document) get(section string, key string) (string, bool) {
+	for _, l := range d.lines {
+		if l.key == key && l.section == section {
+			return l.value, true
+		}
+	}
+	return "", false
+}
+
+// keys returns the keys in section starting with prefix, in file order
+func (d *document) keys(section string, prefix string) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, l := range d.lines {
+		if l.key == "" || l.section != section || !strings.HasPrefix(l.key, prefix) || seen[l.key] {
+			continue
+		}
+		seen[l.key] = true
+		keys = append(keys, l.key)
+	}
+	return keys
+}
+
+// set updates the first occurrence of key in section, drops duplicates and
+// adds the key at the end of the section if it is missing. Keys match without
+// case, a new key is written as given
+func (d *document) set(section string, name string, value string) {
+	key := strings.ToLower(name)
+	isSet := false
+	out := d.lines[:0]
+	for _, l := range d.lines {
+		if l.key == key && l.section == section {
+			if isSet {
+				continue
+			}
+			l.value = value
+			isSet = true
+		}
+		out = append(out, l)
+	}
+	d.lines = out
+	if isSet {
+		return
+	}
+
+	l := &line{section: section, key: key, name: name, value: value}
+	index := d.sectionEnd(section)
+	if index < 0 {
+		if len(d.lines) > 0 {
+			d.lines = append(d.lines, &line{section: section})
+		}
+		d.lines = append(d.lines, &line{raw: "[" + section + "]", section: section, isSection: true}, l)
+		return
+	}
+	d.lines = append(d.lines[:index], append([]*line{l}, d.lines[index:]...)...)
+}
+
+// sectionEnd returns the index after the last entry of section, or -1 if the section does not exist
+func (d *document) sectionEnd(section string) int {
+	index := -1
+	if section == "" {
+		index = 0
+	}
+	for i, l := range d.lines {
+		if l.section != section {
+			continue
+		}
+		if l.isSection || l.key != "" || (section == "" && strings.TrimSpace(l.raw) != "") {
+			index = i + 1
+		}
+	}
+	return index
+}
+
+// remove deletes every occurrence of key in section
+func (d *document) remove(section string, key string) {
+	out := d.lines[:0]
+	for _, l := range d.lines {
+		if l.key == key && l.section == section {
+			continue
+		}
+		out = append(out, l)
+	}
+	d.lines = out
+}
+
+func (d *document) write(w io.Writer) error {
+	for _, l := range d.lines {
+		text := l.raw
+		if l.key != "" {
+			text = fmt.Sprintf("%s = %s", l.name, quote(l.value))
+		}
+		_, err := fmt.Fprintln(w, text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unquote strips TOML style "double" or 'single' quotes from a value
+func unquote(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	if value[0] == '"' && value[len(value)-1] == '"' {
+		out, err := strconv.Unquote(value)
+		if err == nil {
+			return out
+		}
+	}
+	if value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// quote quotes a value that would not survive being read back as is
+func quote(value string) string {
+	if value != strings.TrimSpace(value) || unquote(value) != value {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/xackery/starteq/slog"
+)
+
+// configVersion is the current config format, written as config_version
+const configVersion = 1
+
+// migration upgrades a document from version to version+1
+type migration struct {
+	version int
+	name    string
+	apply   func(doc *document) error
+}
+
+// migrations is applied in order to any config older than configVersion
+var migrations = []migration{
+	{version: 0, name: "copy version to patch_version and normalize booleans", apply: migrateV0},
+}
+
+// migrate upgrades doc to configVersion. If anything changed the original file
+// is kept as .ini.bak and the upgraded file is written
+func (c *Config) migrate(doc *document) error {
+	version := 0
+	value, ok := doc.get("", "config_version")
+	if ok {
+		var err error
+		version, err = strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("config_version %q is not a number", value)
+		}
+	}
+	if version > configVersion {
+		slog.Warn("Config is newer than this launcher supports", "config_version", version, "supported", configVersion)
+		return nil
+	}
+	if version == configVersion {
+		return nil
+	}
+
+	for _, m := range migrations {
+		if m.version < version {
+			continue
+		}
+		slog.Info("Migrating config", "from", m.version, "to", m.version+1, "step", m.name)
+		err := m.apply(doc)
+		if err != nil {
+			return fmt.Errorf("migrate %d to %d: %w", m.version, m.version+1, err)
+		}
+	}
+	doc.set("", "config_version", strconv.Itoa(configVersion))
+
+	path := c.baseName + ".ini"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	err = os.WriteFile(path+".bak", data, 0644)
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", path, err)
+	}
+	err = c.write(doc)
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	slog.Info("Migrated config", "file", path, "from", version, "to", configVersion, "backup", path+".bak")
+	return nil
+}
+
+// migrateV0 copies version, which was ambiguous next to config_version, to
+// patch_version and rewrites 1/0 booleans as true/false. version is kept for
+// older launchers. Other values are kept for Verify to report, they read as
+// false like they always did
+func migrateV0(doc *document) error {
+	value, ok := doc.get("", "version")
+	_, isExisting := doc.get("", "patch_version")
+	if ok && !isExisting {
+		doc.set("", "patch_version", value)
+	}
+	for _, key := range []string{"auto_patch", "auto_play", "torrent_ok"} {
+		value, ok := doc.get("", key)
+		if !ok {
+			continue
+		}
+		b, err := parseBool(value)
+		if err != nil {
+			continue
+		}
+		doc.set("", key, strconv.FormatBool(b))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// remoteValue remembers the local value of a field overwritten by ApplyRemote
+type remoteValue struct {
+	value   string // value applied from the server
+	local   string // value before it was applied
+	isLocal bool   // true if the local ini set the field
+}
+
+// Set parses value into the setting name, written as key or section.key
+func (c *Config) Set(name string, value string) error {
+	f, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	if f.validate != nil {
+		err = f.validate(c, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name(), err)
+		}
+	}
+	err = f.set(c, value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.name(), err)
+	}
+	delete(c.invalid, f.name())
+	return nil
+}
+
+// Get returns the setting name, written as key or section.key, as it appears in the ini
+func (c *Config) Get(name string) (string, error) {
+	f, err := lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return f.get(c), nil
+}
+
+// ApplyRemote layers settings provided by the patch server over the local
+// ini. Defaults only apply to settings the local ini leaves unset, overrides
+// apply unless the setting lets the local ini win. Applied values are not
+// written back by Save. Every rejected setting is returned
+func (c *Config) ApplyRemote(defaults map[string]string, overrides map[string]string) []error {
+	errs := []error{}
+	for _, name := range sortedKeys(defaults) {
+		err := c.applyRemote(name, defaults[name], false)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, name := range sortedKeys(overrides) {
+		err := c.applyRemote(name, overrides[name], true)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (c *Config) applyRemote(name string, value string, isOverride bool) error {
+	f, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	if f.remote == remoteNone {
+		return fmt.Errorf("%s may not be set by the server", f.name())
+	}
+	isLocal := c.local[f.name()]
+	if isLocal && (!isOverride || f.remote == remoteLocalWins) {
+		return nil
+	}
+	if f.bound != nil {
+		value = f.bound(value)
+	}
+	rv, ok := c.remote[f.name()]
+	if !ok {
+		rv = remoteValue{local: f.get(c), isLocal: isLocal}
+	}
+	err = c.Set(f.name(), value)
+	if err != nil {
+		return err
+	}
+	rv.value = f.get(c)
+	c.remote[f.name()] = rv
+	return nil
+}
+
+// lookup finds the field written as key or section.key
+func lookup(name string) (*field, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i := range fields {
+		f := &fields[i]
+		if f.kind == kindMap {
+			continue
+		}
+		if f.name() == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown setting %s", name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
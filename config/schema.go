@@ -0,0 +1,317 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xackery/starteq/slog"
+)
+
+// kind is how a field value is stored in the ini file
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindList // comma separated values
+	kindEnv  // every key in the section starting with the field key is an environment variable, keeping its case
+	kindMap  // every key in the section starting with the field key, each a comma separated list
+	kindFloat
+)
+
+// maxDownloads is the most files that may be downloaded at once
+const maxDownloads = 16
+
+// remoteMode is how server provided launcher settings interact with a field
+type remoteMode int
+
+const (
+	remoteNone       remoteMode = iota // the server may not set the field
+	remoteLocalWins                    // the server may set it, a value in the local ini wins
+	remoteServerWins                   // the server may set it, server overrides beat the local ini
+)
+
+// field describes a single setting in the ini file
+type field struct {
+	section  string // "" for top level
+	key      string
+	kind     kind
+	def      string
+	isAlways bool                                // written even when the value is the default
+	remote   remoteMode                          // whether launcher.yml from the server may set it
+	ptr      func(c *Config) interface{}         // returns a pointer to the Config member
+	validate func(c *Config, value string) error // optional, called with the formatted value
+	bound    func(value string) string           // optional, brings a value from the server into range instead of rejecting it
+}
+
+// fields is every setting known to the config, in the order new keys are written
+var fields = []field{
+	{key: "config_version", kind: kindInt, def: strconv.Itoa(configVersion), isAlways: true, ptr: func(c *Config) interface{} { return &c.configVersion }},
+	// version is the old name of patch_version, still written so a launcher rolled back to before it was renamed knows what is installed
+	{key: "version", kind: kindString, ptr: func(c *Config) interface{} { return &c.Version }},
+	{key: "patch_version", kind: kindString, ptr: func(c *Config) interface{} { return &c.Version }},
+	{key: "auto_patch", kind: kindBool, def: "false", isAlways: true, remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.IsAutoPatch }},
+	{key: "auto_play", kind: kindBool, def: "false", isAlways: true, remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.IsAutoPlay }},
+	{key: "torrent_ok", kind: kindBool, def: "false", ptr: func(c *Config) interface{} { return &c.IsTorrentOK }},
+	{key: "channel", kind: kindString, def: "stable", ptr: func(c *Config) interface{} { return &c.Channel }, validate: validateChannel},
+	{key: "pin_version", kind: kindString, ptr: func(c *Config) interface{} { return &c.PinVersion }},
+	{key: "log_level", kind: kindString, def: "info", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.LogLevel }, validate: validateLogLevel},
+	{section: "launch", key: "delay", kind: kindInt, def: "5", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.LaunchDelay }, validate: validateNotNegative},
+	{section: "launch", key: "exe", kind: kindString, ptr: func(c *Config) interface{} { return &c.LaunchExe }},
+	{section: "launch", key: "args", kind: kindString, ptr: func(c *Config) interface{} { return &c.LaunchArgs }},
+	{section: "launch", key: "env.", kind: kindEnv, ptr: func(c *Config) interface{} { return &c.LaunchEnv }, validate: validateEnv},
+	{section: "launch", key: "dir", kind: kindString, ptr: func(c *Config) interface{} { return &c.LaunchDir }},
+	{section: "launch", key: "pre_launch", kind: kindString, ptr: func(c *Config) interface{} { return &c.PreLaunch }},
+	{section: "launch", key: "post_exit", kind: kindString, ptr: func(c *Config) interface{} { return &c.PostExit }},
+	{section: "server", key: "login_host", kind: kindString, remote: remoteServerWins, ptr: func(c *Config) interface{} { return &c.LoginHost }},
+	{section: "server", key: "max_downloads", kind: kindInt, def: "1", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.MaxDownloads }, validate: validateMaxDownloads, bound: boundMaxDownloads},
+	{section: "server", key: "mirrors", kind: kindList, ptr: func(c *Config) interface{} { return &c.Mirrors }, validate: validateURLs},
+	{section: "patch", key: "protected", kind: kindList, ptr: func(c *Config) interface{} { return &c.Protected }, validate: validateGlobs},
+	{section: "patch", key: "orphans", kind: kindString, def: "prompt", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.Orphans }, validate: validateOrphans},
+	{section: "torrent", key: "seed", kind: kindBool, def: "false", ptr: func(c *Config) interface{} { return &c.Seed }},
+	{section: "torrent", key: "upload_limit", kind: kindInt, def: "0", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.SeedUploadLimit }, validate: validateNotNegative},
+	{section: "torrent", key: "seed_ratio", kind: kindFloat, def: "0", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.SeedRatio }, validate: validateNotNegative},
+	{section: "torrent", key: "seed_minutes", kind: kindInt, def: "0", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.SeedMinutes }, validate: validateNotNegative},
+	{section: "torrent", key: "web_seeds", kind: kindList, ptr: func(c *Config) interface{} { return &c.WebSeeds }, validate: validateURLs},
+	{section: "torrent", key: "trackers", kind: kindList, ptr: func(c *Config) interface{} { return &c.Trackers }, validate: validateTrackers},
+	{section: "download", key: "source", kind: kindString, def: "auto", remote: remoteLocalWins, ptr: func(c *Config) interface{} { return &c.BaseSource }, validate: validateSource},
+	{section: "download", key: "archive_url", kind: kindList, ptr: func(c *Config) interface{} { return &c.ArchiveURLs }, validate: validateURLs},
+	{section: "download", key: "archive_size", kind: kindInt, def: "0", ptr: func(c *Config) interface{} { return &c.ArchiveSize }, validate: validateNotNegative},
+	{section: "download", key: "archive_md5", kind: kindString, ptr: func(c *Config) interface{} { return &c.ArchiveMd5 }},
+	{section: "wine", key: "runner", kind: kindString, ptr: func(c *Config) interface{} { return &c.WineRunner }},
+	{section: "wine", key: "prefix", kind: kindString, ptr: func(c *Config) interface{} { return &c.WinePrefix }},
+	{section: "profiles", key: "", kind: kindMap, ptr: func(c *Config) interface{} { return &c.Profiles }},
+}
+
+// name returns the field as written in errors, section.key or key
+func (f *field) name() string {
+	if f.section == "" {
+		return f.key
+	}
+	return f.section + "." + strings.TrimSuffix(f.key, ".")
+}
+
+// set parses value into the Config member
+func (f *field) set(c *Config, value string) error {
+	switch p := f.ptr(c).(type) {
+	case *string:
+		*p = value
+	case *bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*p = i
+	case *float64:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*p = v
+	case *[]string:
+		if f.kind == kindEnv {
+			*p = splitEnv(value)
+			return nil
+		}
+		*p = splitList(value)
+	default:
+		return fmt.Errorf("unsupported field type %T", p)
+	}
+	return nil
+}
+
+// get formats the Config member as written to the ini file
+func (f *field) get(c *Config) string {
+	switch p := f.ptr(c).(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'f', -1, 64)
+	case *[]string:
+		if f.kind == kindEnv {
+			return strings.Join(*p, "\n")
+		}
+		return strings.Join(*p, ", ")
+	}
+	return ""
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes", "on":
+		return true, nil
+	case "false", "0", "no", "off", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not true or false", value)
+}
+
+func validateLogLevel(c *Config, value string) error {
+	_, err := slog.ParseLevel(value)
+	return err
+}
+
+func validateChannel(c *Config, value string) error {
+	switch value {
+	case "stable", "beta":
+		return nil
+	}
+	return fmt.Errorf("%q must be stable or beta", value)
+}
+
+func validateOrphans(c *Config, value string) error {
+	switch value {
+	case "off", "prompt", "auto":
+		return nil
+	}
+	return fmt.Errorf("%q must be off, prompt or auto", value)
+}
+
+func validateSource(c *Config, value string) error {
+	switch value {
+	case "auto", "torrent", "http":
+		return nil
+	}
+	return fmt.Errorf("%q must be auto, torrent or http", value)
+}
+
+func validateNotNegative(c *Config, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+// boundMaxDownloads clamps a number to 1..maxDownloads, other values are left for validateMaxDownloads
+func boundMaxDownloads(value string) string {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return value
+	}
+	if i < 1 {
+		i = 1
+	}
+	if i > maxDownloads {
+		i = maxDownloads
+	}
+	return strconv.Itoa(i)
+}
+
+func validateMaxDownloads(c *Config, value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	if i < 1 || i > maxDownloads {
+		return fmt.Errorf("must be between 1 and %d", maxDownloads)
+	}
+	return nil
+}
+
+func validateURLs(c *Config, value string) error {
+	for _, entry := range splitList(value) {
+		err := ValidateURL(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateURL returns an error if value is not an http or https url
+func ValidateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%q: %w", value, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must be an http or https url", value)
+	}
+	return nil
+}
+
+func validateGlobs(c *Config, value string) error {
+	for _, pattern := range splitList(value) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func validateTrackers(c *Config, value string) error {
+	for _, entry := range splitList(value) {
+		err := ValidateTracker(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateTracker returns an error if value is not an http, https or udp tracker url
+func ValidateTracker(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%q: %w", value, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "udp" {
+		return fmt.Errorf("%q must be an http, https or udp url", value)
+	}
+	return nil
+}
+
+func validateEnv(c *Config, value string) error {
+	for _, env := range splitEnv(value) {
+		name := env
+		index := strings.Index(env, "=")
+		if index >= 0 {
+			name = env[:index]
+		}
+		if index <= 0 || strings.ContainsAny(name, " \t") {
+			return fmt.Errorf("%q is not a valid environment variable name", name)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	out := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// splitEnv splits KEY=VALUE pairs, one per line
+func splitEnv(value string) []string {
+	out := []string{}
+	for _, entry := range strings.Split(value, "\n") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		out = append(out, entry)
+	}
+	return out
+}
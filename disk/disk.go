@@ -0,0 +1,70 @@
+// Package disk checks free space on the volume holding a path
+package disk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/c2h5oh/datasize"
+)
+
+// SpaceError is returned by Check when a volume is too full
+type SpaceError struct {
+	Path   string
+	Need   uint64 // bytes the download or copy takes
+	Margin uint64 // bytes kept free on top of Need
+	Free   uint64
+}
+
+// Error implements error
+func (e *SpaceError) Error() string {
+	if e.Margin == 0 {
+		return fmt.Sprintf("not enough disk space for %s: %s needed, %s free, free up %s and try again",
+			e.Path, size(e.Need), size(e.Free), size(e.Need-e.Free))
+	}
+	return fmt.Sprintf("not enough disk space for %s: %s needed and %s kept spare, %s free, free up %s and try again",
+		e.Path, size(e.Need), size(e.Margin), size(e.Free), size(e.Need+e.Margin-e.Free))
+}
+
+// Check returns a SpaceError if the volume holding path has less than need
+// bytes free with margin bytes to spare
+func Check(path string, need uint64, margin uint64) error {
+	free, err := Free(path)
+	if err != nil {
+		return err
+	}
+	if free < need+margin {
+		return &SpaceError{Path: path, Need: need, Margin: margin, Free: free}
+	}
+	return nil
+}
+
+// Free returns the bytes available to the current user on the volume holding
+// path. path does not need to exist yet, its closest existing parent is used
+func Free(path string) (uint64, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return 0, fmt.Errorf("abs: %w", err)
+	}
+	for {
+		_, err = os.Stat(abs)
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return 0, fmt.Errorf("stat %s: %w", path, err)
+		}
+		abs = parent
+	}
+	free, err := free(abs)
+	if err != nil {
+		return 0, fmt.Errorf("free space of %s: %w", abs, err)
+	}
+	return free, nil
+}
+
+func size(n uint64) string {
+	return (datasize.ByteSize(n) * datasize.B).HR()
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package disk
+
+import "golang.org/x/sys/unix"
+
+func free(path string) (uint64, error) {
+	stat := unix.Statfs_t{}
+	err := unix.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -9,22 +9,59 @@ import (
 	"github.com/xackery/starteq/config"
 )
 
-var ()
+var (
+	shown func()
+)
 
 func NewMainWindow(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, splash []byte) error {
 	return nil
 }
 
 func Run() int {
+	if shown != nil {
+		shown()
+	}
 	return 0
 }
 
+// SubscribeShown subscribes to the window being shown by Run
+func SubscribeShown(fn func()) {
+	shown = fn
+}
+
 func SubscribePatchButton(fn func()) {
 }
 
 func SubscribePlayButton(fn func()) {
 }
 
+func SubscribeMultibox(fn func()) {
+}
+
+func SelectAccounts(title string, accounts []string, selected []string) ([]string, bool) {
+	return nil, false
+}
+
+func SubscribeRepair(fn func()) {
+}
+
+func SubscribeInstallVersion(fn func()) {
+}
+
+func SubscribeImportInstall(fn func()) {
+}
+
+func BrowseFolder(title string) (string, bool) {
+	return "", false
+}
+
+func SelectVersion(title string, versions []string, selected int) (int, bool) {
+	return -1, false
+}
+
+func SubscribeExportDiagnostics(fn func()) {
+}
+
 func SubscribeAutoPatch(fn func()) {
 }
 
@@ -72,6 +109,9 @@ func MessageBoxYesNo(title, message string) bool {
 func MessageBox(title, message string, isError bool) {
 }
 
+func SetStatus(text string) {
+}
+
 func SetTitle(title string) {
 
 }
@@ -14,26 +14,36 @@ import (
 	"github.com/xackery/starteq/config"
 	"github.com/xackery/starteq/slog"
 	"github.com/xackery/wlk/walk"
+	"github.com/xackery/wlk/win"
 )
 
 type Gui struct {
-	ctx         context.Context
-	cancel      context.CancelFunc
-	mw          *walk.MainWindow
-	splash      *walk.ImageView
-	isAutoPatch *walk.CheckBox
-	isAutoPlay  *walk.CheckBox
-	patchButton *walk.PushButton
-	playButton  *walk.PushButton
-	progress    *walk.ProgressBar
-	log         *walk.TextEdit
-	isRunning   bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mw            *walk.MainWindow
+	splash        *walk.ImageView
+	isAutoPatch   *walk.CheckBox
+	isAutoPlay    *walk.CheckBox
+	patchButton   *walk.PushButton
+	playButton    *walk.PushButton
+	progress      *walk.ProgressBar
+	log           *walk.TextEdit
+	toolsMenu     *walk.Menu
+	diagAction    *walk.Action
+	repairAction  *walk.Action
+	multiAction   *walk.Action
+	installAction *walk.Action
+	importAction  *walk.Action
+	status        *walk.StatusBarItem
+	isRunning     bool
+	shown         func() // called on the UI thread once Run shows the window
 }
 
 var (
 	gui        *Gui
 	isAutoMode bool
 	mu         sync.RWMutex
+	uiThread   uint32 // thread running the window's message loop
 )
 
 // NewMainWindow creates a new main window
@@ -44,6 +54,7 @@ func NewMainWindow(ctx context.Context, cancel context.CancelFunc, cfg *config.C
 		cancel: cancel,
 	}
 	isAutoMode = true
+	uiThread = win.GetCurrentThreadId()
 
 	var err error
 	gui.mw, err = walk.NewMainWindowWithName("starteq")
@@ -51,10 +62,45 @@ func NewMainWindow(ctx context.Context, cancel context.CancelFunc, cfg *config.C
 		return fmt.Errorf("new main window: %w", err)
 	}
 	gui.mw.SetTitle("Start EQ (Client: Rain of Fear 2)")
-	gui.mw.SetMinMaxSize(walk.Size{Width: 305, Height: 371}, walk.Size{Width: 305, Height: 371})
+	gui.mw.SetMinMaxSize(walk.Size{Width: 305, Height: 391}, walk.Size{Width: 305, Height: 391})
 	gui.mw.SetLayout(walk.NewVBoxLayout())
 	gui.mw.SetVisible(false)
 
+	gui.toolsMenu, err = walk.NewMenu()
+	if err != nil {
+		return fmt.Errorf("new menu: %w", err)
+	}
+	toolsAction, err := gui.mw.Menu().Actions().AddMenu(gui.toolsMenu)
+	if err != nil {
+		return fmt.Errorf("add menu: %w", err)
+	}
+	toolsAction.SetText("&Tools")
+
+	gui.multiAction, err = newToolAction("&Multibox...")
+	if err != nil {
+		return fmt.Errorf("new tool action: %w", err)
+	}
+
+	gui.repairAction, err = newToolAction("&Repair install")
+	if err != nil {
+		return fmt.Errorf("new tool action: %w", err)
+	}
+
+	gui.installAction, err = newToolAction("&Install version...")
+	if err != nil {
+		return fmt.Errorf("new tool action: %w", err)
+	}
+
+	gui.importAction, err = newToolAction("I&mport install...")
+	if err != nil {
+		return fmt.Errorf("new tool action: %w", err)
+	}
+
+	gui.diagAction, err = newToolAction("Export &diagnostics")
+	if err != nil {
+		return fmt.Errorf("new tool action: %w", err)
+	}
+
 	// convert splash from byte slice to png
 	splashImg, err := png.Decode(bytes.NewReader(splash))
 	if err != nil {
@@ -137,20 +183,79 @@ func NewMainWindow(ctx context.Context, cancel context.CancelFunc, cfg *config.C
 	gui.progress.SetMinMaxSize(walk.Size{Width: 400, Height: 39}, walk.Size{Width: 400, Height: 39})
 
 	gui.mw.Children().Add(gui.progress)
-	gui.mw.SetSize(walk.Size{Width: 305, Height: 371})
+
+	gui.status = walk.NewStatusBarItem()
+	err = gui.mw.StatusBar().Items().Add(gui.status)
+	if err != nil {
+		return fmt.Errorf("add status bar item: %w", err)
+	}
+	gui.mw.StatusBar().SetVisible(false)
+	gui.mw.SetSize(walk.Size{Width: 305, Height: 391})
 
 	return nil
 }
 
+// newToolAction adds an entry to the tools menu
+func newToolAction(text string) (*walk.Action, error) {
+	action := walk.NewAction()
+	err := action.SetText(text)
+	if err != nil {
+		return nil, fmt.Errorf("set text: %w", err)
+	}
+	err = gui.toolsMenu.Actions().Add(action)
+	if err != nil {
+		return nil, fmt.Errorf("add action: %w", err)
+	}
+	return action, nil
+}
+
 func Run() int {
 	if gui == nil {
 		return 1
 	}
+	mu.Lock()
 	gui.isRunning = true
+	shown := gui.shown
+	mu.Unlock()
 	gui.mw.SetVisible(true)
+	if shown != nil {
+		// runs once the message loop below starts
+		gui.mw.Synchronize(shown)
+	}
 	return gui.mw.Run()
 }
 
+// SubscribeShown subscribes to the window being shown by Run
+func SubscribeShown(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.shown = fn
+}
+
+// onUIThread runs fn on the window's thread and waits for it to return, so a
+// dialog opened from a goroutine doesn't run a second message loop. Before Run,
+// fn is called directly
+func onUIThread(fn func()) {
+	mu.Lock()
+	if gui == nil || !gui.isRunning || win.GetCurrentThreadId() == uiThread {
+		mu.Unlock()
+		fn()
+		return
+	}
+	mw := gui.mw
+	mu.Unlock()
+
+	done := make(chan struct{})
+	mw.Synchronize(func() {
+		defer close(done)
+		fn()
+	})
+	<-done
+}
+
 // SubscribePatchButton subscribes to the patch button
 func SubscribePatchButton(fn func()) {
 	mu.Lock()
@@ -171,6 +276,237 @@ func SubscribePlayButton(fn func()) {
 	gui.playButton.Clicked().Attach(fn)
 }
 
+// SubscribeMultibox subscribes to the multibox menu entry
+func SubscribeMultibox(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.multiAction.Triggered().Attach(fn)
+}
+
+// SelectAccounts shows a list of accounts to pick from, returning the chosen
+// accounts and false if the dialog was cancelled
+func SelectAccounts(title string, accounts []string, selected []string) ([]string, bool) {
+	mu.Lock()
+	if gui == nil {
+		mu.Unlock()
+		return nil, false
+	}
+	owner := gui.mw
+	mu.Unlock()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		slog.Print("Failed to create dialog: %s", err)
+		return nil, false
+	}
+	defer dlg.Dispose()
+	dlg.SetTitle(title)
+	dlg.SetLayout(walk.NewVBoxLayout())
+	dlg.SetMinMaxSize(walk.Size{Width: 250, Height: 300}, walk.Size{Width: 500, Height: 600})
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		slog.Print("Failed to create label: %s", err)
+		return nil, false
+	}
+	label.SetText("Select the accounts to launch:")
+
+	list, err := walk.NewListBoxWithStyle(dlg, win.LBS_EXTENDEDSEL)
+	if err != nil {
+		slog.Print("Failed to create list: %s", err)
+		return nil, false
+	}
+	err = list.SetModel(accounts)
+	if err != nil {
+		slog.Print("Failed to set accounts: %s", err)
+		return nil, false
+	}
+	indexes := []int{}
+	for i, account := range accounts {
+		for _, sel := range selected {
+			if account == sel {
+				indexes = append(indexes, i)
+				break
+			}
+		}
+	}
+	list.SetSelectedIndexes(indexes)
+
+	comp, err := walk.NewComposite(dlg)
+	if err != nil {
+		slog.Print("Failed to create composite: %s", err)
+		return nil, false
+	}
+	comp.SetLayout(walk.NewHBoxLayout())
+	okButton, err := walk.NewPushButton(comp)
+	if err != nil {
+		slog.Print("Failed to create button: %s", err)
+		return nil, false
+	}
+	okButton.SetText("Launch")
+	cancelButton, err := walk.NewPushButton(comp)
+	if err != nil {
+		slog.Print("Failed to create button: %s", err)
+		return nil, false
+	}
+	cancelButton.SetText("Cancel")
+	dlg.SetDefaultButton(okButton)
+	dlg.SetCancelButton(cancelButton)
+
+	result := []string{}
+	okButton.Clicked().Attach(func() {
+		for _, index := range list.SelectedIndexes() {
+			result = append(result, accounts[index])
+		}
+		dlg.Accept()
+	})
+	cancelButton.Clicked().Attach(dlg.Cancel)
+
+	if dlg.Run() != walk.DlgCmdOK {
+		return nil, false
+	}
+	return result, true
+}
+
+// SubscribeRepair subscribes to the repair install menu entry
+func SubscribeRepair(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.repairAction.Triggered().Attach(fn)
+}
+
+// SubscribeInstallVersion subscribes to the install version menu entry
+func SubscribeInstallVersion(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.installAction.Triggered().Attach(fn)
+}
+
+// SubscribeImportInstall subscribes to the import install menu entry
+func SubscribeImportInstall(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.importAction.Triggered().Attach(fn)
+}
+
+// BrowseFolder asks for a folder, returning false if the dialog was cancelled
+func BrowseFolder(title string) (string, bool) {
+	mu.Lock()
+	if gui == nil {
+		mu.Unlock()
+		return "", false
+	}
+	owner := gui.mw
+	mu.Unlock()
+
+	dlg := &walk.FileDialog{Title: title}
+	ok, err := dlg.ShowBrowseFolder(owner)
+	if err != nil {
+		slog.Print("Failed to browse for folder: %s", err)
+		return "", false
+	}
+	if !ok || dlg.FilePath == "" {
+		return "", false
+	}
+	return dlg.FilePath, true
+}
+
+// SelectVersion shows a list of patch versions to pick one from, returning the
+// chosen index and false if the dialog was cancelled
+func SelectVersion(title string, versions []string, selected int) (int, bool) {
+	mu.Lock()
+	if gui == nil {
+		mu.Unlock()
+		return -1, false
+	}
+	owner := gui.mw
+	mu.Unlock()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		slog.Print("Failed to create dialog: %s", err)
+		return -1, false
+	}
+	defer dlg.Dispose()
+	dlg.SetTitle(title)
+	dlg.SetLayout(walk.NewVBoxLayout())
+	dlg.SetMinMaxSize(walk.Size{Width: 300, Height: 300}, walk.Size{Width: 600, Height: 600})
+
+	label, err := walk.NewLabel(dlg)
+	if err != nil {
+		slog.Print("Failed to create label: %s", err)
+		return -1, false
+	}
+	label.SetText("Select the version to install:")
+
+	list, err := walk.NewListBox(dlg)
+	if err != nil {
+		slog.Print("Failed to create list: %s", err)
+		return -1, false
+	}
+	err = list.SetModel(versions)
+	if err != nil {
+		slog.Print("Failed to set versions: %s", err)
+		return -1, false
+	}
+	list.SetCurrentIndex(selected)
+
+	comp, err := walk.NewComposite(dlg)
+	if err != nil {
+		slog.Print("Failed to create composite: %s", err)
+		return -1, false
+	}
+	comp.SetLayout(walk.NewHBoxLayout())
+	okButton, err := walk.NewPushButton(comp)
+	if err != nil {
+		slog.Print("Failed to create button: %s", err)
+		return -1, false
+	}
+	okButton.SetText("Install")
+	cancelButton, err := walk.NewPushButton(comp)
+	if err != nil {
+		slog.Print("Failed to create button: %s", err)
+		return -1, false
+	}
+	cancelButton.SetText("Cancel")
+	dlg.SetDefaultButton(okButton)
+	dlg.SetCancelButton(cancelButton)
+
+	result := -1
+	okButton.Clicked().Attach(func() {
+		result = list.CurrentIndex()
+		dlg.Accept()
+	})
+	cancelButton.Clicked().Attach(dlg.Cancel)
+
+	if dlg.Run() != walk.DlgCmdOK || result < 0 {
+		return -1, false
+	}
+	return result, true
+}
+
+// SubscribeExportDiagnostics subscribes to the export diagnostics menu entry
+func SubscribeExportDiagnostics(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.diagAction.Triggered().Attach(fn)
+}
+
 func SubscribeAutoPatch(fn func()) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -286,40 +622,62 @@ func SetProgress(value int) {
 
 func MessageBox(title string, message string, isError bool) {
 	mu.Lock()
-	defer mu.Unlock()
-	owner := gui.mw
-	if gui == nil || !gui.isRunning {
-		owner = nil
+	var owner walk.Form
+	if gui != nil && gui.isRunning {
+		owner = gui.mw
 	}
+	mu.Unlock()
 	// convert style to msgboxstyle
 	icon := walk.MsgBoxIconInformation
 	if isError {
 		icon = walk.MsgBoxIconError
 	}
-	walk.MsgBox(owner, title, message, icon)
+	onUIThread(func() {
+		walk.MsgBox(owner, title, message, icon)
+	})
 }
 
 func MessageBoxYesNo(title string, message string) bool {
 	mu.Lock()
-	defer mu.Unlock()
 	if gui == nil {
+		mu.Unlock()
 		return false
 	}
+	owner := gui.mw
+	mu.Unlock()
 	// convert style to msgboxstyle
 	icon := walk.MsgBoxIconInformation
-	result := walk.MsgBox(gui.mw, title, message, icon|walk.MsgBoxYesNo)
+	result := 0
+	onUIThread(func() {
+		result = walk.MsgBox(owner, title, message, icon|walk.MsgBoxYesNo)
+	})
 	return result == walk.DlgCmdYes
 }
 
 func MessageBoxf(title string, format string, a ...interface{}) {
 	mu.Lock()
-	defer mu.Unlock()
 	if gui == nil {
+		mu.Unlock()
 		return
 	}
+	owner := gui.mw
+	mu.Unlock()
 	// convert style to msgboxstyle
 	icon := walk.MsgBoxIconInformation
-	walk.MsgBox(gui.mw, title, fmt.Sprintf(format, a...), icon)
+	onUIThread(func() {
+		walk.MsgBox(owner, title, fmt.Sprintf(format, a...), icon)
+	})
+}
+
+// SetStatus shows text in the status bar, an empty text hides it
+func SetStatus(text string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gui == nil {
+		return
+	}
+	gui.status.SetText(text)
+	gui.mw.StatusBar().SetVisible(text != "")
 }
 
 func SetTitle(title string) {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,6 +19,13 @@ import (
 //go:embed splash.png
 var starteqSplash []byte
 
+const (
+	// logMaxSize is the size a log file may grow to before it is rotated
+	logMaxSize = 5 * 1024 * 1024
+	// logMaxFiles is how many rotated log files are kept
+	logMaxFiles = 3
+)
+
 var (
 	// Version is the current version
 	Version string
@@ -26,6 +34,12 @@ var (
 )
 
 func main() {
+	installVersion := flag.String("install-version", "", "patch to a version published in the server's filelist index, or latest to follow new patches again")
+	importDir := flag.String("import", "", "import an existing EverQuest RoF2 install from this folder into the game folder")
+	importLink := flag.Bool("import-link", false, "hardlink the files of -import instead of copying them")
+	profile := flag.String("profile", "", "patch if auto patch is on, then launch every account in this launch profile")
+	flag.Parse()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	exeName, err := os.Executable()
@@ -40,11 +54,26 @@ func main() {
 	if baseName == "" {
 		baseName = "starteq"
 	}
+	err = slog.SetFile(baseName+".txt", logMaxSize, logMaxFiles)
+	if err != nil {
+		fmt.Println("Failed to open log file:", err)
+	}
+	defer slog.Close()
+
 	cfg, err := config.New(context.Background(), baseName)
 	if err != nil {
 		gui.MessageBox("Error", "Failed to load config: "+err.Error(), true)
 		os.Exit(1)
 	}
+	err = cfg.Verify()
+	if err != nil {
+		slog.Warn("Problems found in "+baseName+".ini", "error", err)
+	}
+	level, err := slog.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		slog.Warn("Invalid log_level, using info", "value", cfg.LogLevel)
+	}
+	slog.SetLevel(level)
 
 	err = gui.NewMainWindow(ctx, cancel, cfg, starteqSplash)
 	if err != nil {
@@ -63,6 +92,7 @@ func main() {
 	}
 	defer slog.Dump(baseName + ".txt")
 	defer c.Done()
+	gui.SubscribeShown(c.ConfirmUpdate)
 
 	gui.SubscribeClose(func(canceled *bool, reason byte) {
 		if ctx.Err() != nil {
@@ -85,11 +115,37 @@ func main() {
 		os.Exit(0)
 	}()
 
-	err = c.AutoPlay()
-	if err == nil {
-		// no gui needed if auto play worked with zero errors
-		fmt.Println("Autoplay worked cleanly, exiting")
-		return
+	if *importDir != "" {
+		err = c.ImportInstall(*importDir, *importLink)
+		if err != nil {
+			slog.Print("Failed to import %s: %s", *importDir, err)
+		}
+	} else if *profile != "" {
+		if cfg.IsAutoPatch {
+			err = c.Patch()
+			if err != nil {
+				slog.Print("Failed to patch: %s", err)
+			}
+		}
+		err = c.PlayProfile(*profile)
+		if err != nil {
+			slog.Print("Failed to play profile %s: %s", *profile, err)
+		}
+	} else if *installVersion != "" {
+		err = c.InstallVersion(*installVersion)
+		if err != nil {
+			slog.Print("Failed to install version %s: %s", *installVersion, err)
+		}
+	} else {
+		err = c.AutoPlay()
+		if err == nil {
+			// no gui needed if auto play worked with zero errors, but stay
+			// around to report a crash and offer a repair
+			fmt.Println("Autoplay worked cleanly, waiting for EverQuest to exit")
+			c.ConfirmUpdate()
+			c.WaitGames()
+			return
+		}
 	}
 	slog.Dump(baseName + ".txt")
 
@@ -0,0 +1,121 @@
+package slog
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// rotateRetry is how long a log that failed to rotate grows before rotation is tried again
+const rotateRetry = 10 * time.Minute
+
+// fileSink is a log file that rotates when it grows past maxSize
+type fileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+	retryAt  time.Time // rotation is skipped until then after it failed
+}
+
+func newFileSink(path string, maxSize int64, maxFiles int) (*fileSink, error) {
+	s := &fileSink{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", s.path, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat %s: %w", s.path, err)
+	}
+	s.f = f
+	s.size = fi.Size()
+	return nil
+}
+
+func (s *fileSink) write(entry string) error {
+	var rotateErr error
+	if s.f == nil {
+		err := s.open()
+		if err != nil {
+			return err
+		}
+	}
+	if s.maxSize > 0 && s.size+int64(len(entry)) > s.maxSize && s.size > 0 && time.Now().After(s.retryAt) {
+		rotateErr = s.rotate()
+		if rotateErr != nil {
+			s.retryAt = time.Now().Add(rotateRetry)
+		}
+		if s.f == nil {
+			return fmt.Errorf("rotate: %w", rotateErr)
+		}
+	}
+	n, err := s.f.WriteString(entry)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	if rotateErr != nil {
+		return fmt.Errorf("rotate: %w", rotateErr)
+	}
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and reopens path.
+// path is reopened even if shifting fails, so logging carries on in the
+// current file, such as when another program holds a rotated file open.
+// write then waits rotateRetry before trying again
+func (s *fileSink) rotate() error {
+	s.f.Close()
+	s.f = nil
+	err := s.shift()
+	openErr := s.open()
+	if openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+func (s *fileSink) shift() error {
+	if s.maxFiles < 1 {
+		err := os.Remove(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", s.path, err)
+		}
+		return nil
+	}
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxFiles))
+	for i := s.maxFiles - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rename %s.%d: %w", s.path, i, err)
+		}
+	}
+	err := os.Rename(s.path, s.path+".1")
+	if err != nil {
+		return fmt.Errorf("rename %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *fileSink) sync() error {
+	return s.f.Sync()
+}
+
+func (s *fileSink) close() error {
+	return s.f.Close()
+}
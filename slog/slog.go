@@ -3,14 +3,42 @@ package slog
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	// LevelDebug is verbose output useful when troubleshooting
+	LevelDebug Level = iota
+	// LevelInfo is normal output
+	LevelInfo
+	// LevelWarn is for recoverable problems
+	LevelWarn
+	// LevelError is for failures
+	LevelError
+)
+
+const (
+	// ringSize is the number of entries kept in memory
+	ringSize = 2000
+	// timeFormat is used to prefix entries written to the buffer and file
+	timeFormat = "2006-01-02 15:04:05"
 )
 
 var (
-	mu       sync.RWMutex
-	cacheLog string
+	mu       sync.Mutex
+	level    = LevelInfo
 	handlers []func(format string, a ...interface{})
+	ring     [ringSize]string
+	ringNext int
+	ringLen  int
+	pending  []string
 	isDumped bool
+	sink     *fileSink
 )
 
 func init() {
@@ -19,6 +47,43 @@ func init() {
 	})
 }
 
+// String returns the level name
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a Level
+func ParseLevel(value string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", value)
+}
+
+// SetLevel sets the minimum level that is logged
+func SetLevel(value Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = value
+}
+
 // AddHandler adds a log handler
 func AddHandler(handler func(format string, a ...interface{})) {
 	mu.Lock()
@@ -26,62 +91,169 @@ func AddHandler(handler func(format string, a ...interface{})) {
 	handlers = append(handlers, handler)
 }
 
-// Dump writes the log to a file
+// SetFile opens a rotating log file at path. When the file grows past maxSize
+// bytes it is renamed to path.1 (shifting older files up) and at most maxFiles
+// old files are kept
+func SetFile(path string, maxSize int64, maxFiles int) error {
+	s, err := newFileSink(path, maxSize, maxFiles)
+	if err != nil {
+		return fmt.Errorf("new file sink: %w", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if sink != nil {
+		sink.close()
+	}
+	sink = s
+	pending = nil
+	return nil
+}
+
+// Close closes the log file, if one is open
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+	err := sink.close()
+	sink = nil
+	return err
+}
+
+// Lines returns a copy of the entries kept in memory, oldest first
+func Lines() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]string, 0, ringLen)
+	start := (ringNext - ringLen + ringSize) % ringSize
+	for i := 0; i < ringLen; i++ {
+		out = append(out, ring[(start+i)%ringSize])
+	}
+	return out
+}
+
+// Dump writes the log to a file. If the log file set by SetFile is path it is
+// synced to disk, otherwise entries since the last dump are written to path
 func Dump(path string) error {
-	mu.RLock()
-	defer mu.RUnlock()
-	if len(cacheLog) == 0 {
+	mu.Lock()
+	defer mu.Unlock()
+	if sink != nil && sink.path == path {
+		return sink.sync()
+	}
+	if len(pending) == 0 {
 		return nil
 	}
+	flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
 	if !isDumped {
-		err := os.WriteFile(path, []byte(cacheLog), os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("write log: %w", err)
-		}
-		cacheLog = ""
-		isDumped = true
-		return nil
+		flag = os.O_TRUNC | os.O_CREATE | os.O_WRONLY
 	}
-	//append to existing file instead
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	f, err := os.OpenFile(path, flag, 0644)
 	if err != nil {
 		return fmt.Errorf("open file: %w", err)
 	}
 	defer f.Close()
-	_, err = f.WriteString(cacheLog)
+	_, err = f.WriteString(strings.Join(pending, ""))
 	if err != nil {
 		return fmt.Errorf("write log: %w", err)
 	}
-	cacheLog = ""
+	pending = nil
+	isDumped = true
 	return nil
 }
 
+// Debug logs msg with optional key/value pairs at debug level
+func Debug(msg string, keyvals ...interface{}) {
+	logKV(LevelDebug, msg, keyvals)
+}
+
+// Info logs msg with optional key/value pairs at info level
+func Info(msg string, keyvals ...interface{}) {
+	logKV(LevelInfo, msg, keyvals)
+}
+
+// Warn logs msg with optional key/value pairs at warn level
+func Warn(msg string, keyvals ...interface{}) {
+	logKV(LevelWarn, msg, keyvals)
+}
+
+// Error logs msg with optional key/value pairs at error level
+func Error(msg string, keyvals ...interface{}) {
+	logKV(LevelError, msg, keyvals)
+}
+
 // Printf writes to the log
 func Printf(format string, a ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	for _, handler := range handlers {
-		handler(format, a...)
-	}
-	cacheLog += fmt.Sprintf(format, a...)
+	write(LevelInfo, format, a...)
 }
 
 // Println writes to the log
 func Println(a ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	for _, handler := range handlers {
-		handler("%s\n", fmt.Sprint(a...))
-	}
-	cacheLog += fmt.Sprintf("%s\n", fmt.Sprint(a...))
+	write(LevelInfo, "%s\n", fmt.Sprint(a...))
 }
 
 // Print is similar to printf, but adds a newline
 func Print(format string, a ...interface{}) {
-	mu.RLock()
-	defer mu.RUnlock()
-	for _, handler := range handlers {
-		handler(format+"\n", a...)
+	write(LevelInfo, format+"\n", a...)
+}
+
+func logKV(lvl Level, msg string, keyvals []interface{}) {
+	write(lvl, "%s\n", msg+formatFields(keyvals))
+}
+
+// formatFields renders key/value pairs as " key=value key2=value2"
+func formatFields(keyvals []interface{}) string {
+	if len(keyvals) == 0 {
+		return ""
+	}
+	out := ""
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 >= len(keyvals) {
+			out += fmt.Sprintf(" !BADKEY=%s", quote(key))
+			break
+		}
+		out += fmt.Sprintf(" %s=%s", key, quote(fmt.Sprint(keyvals[i+1])))
+	}
+	return out
+}
+
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		return fmt.Sprintf("%q", value)
+	}
+	return value
+}
+
+func write(lvl Level, format string, a ...interface{}) {
+	mu.Lock()
+	if lvl < level {
+		mu.Unlock()
+		return
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, a...), "\r\n")
+	entry := fmt.Sprintf("%s %-5s %s\n", time.Now().Format(timeFormat), strings.ToUpper(lvl.String()), msg)
+
+	ring[ringNext] = entry
+	ringNext = (ringNext + 1) % ringSize
+	if ringLen < ringSize {
+		ringLen++
+	}
+	if sink != nil {
+		err := sink.write(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "slog: %s\n", err)
+		}
+	} else if len(pending) < ringSize {
+		pending = append(pending, entry)
+	}
+
+	// handlers are called outside the lock so a handler may log itself
+	hs := make([]func(format string, a ...interface{}), len(handlers))
+	copy(hs, handlers)
+	mu.Unlock()
+
+	for _, handler := range hs {
+		handler(format, a...)
 	}
-	cacheLog += fmt.Sprintf(format+"\n", a...)
 }
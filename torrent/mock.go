@@ -1,10 +1,110 @@
 package torrent
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
 
+// Mock is a Torrenter that does not touch the network. It reports progress in
+// Steps, then fails with Err or writes Files under the data folder
 type Mock struct {
+	Steps int               // progress reports before the download ends, 0 ends at once
+	Delay time.Duration     // wait between progress reports
+	Total int64             // size reported in progress
+	Err   error             // returned instead of finishing, to simulate a failed download
+	Files map[string][]byte // written under DataDir when the download succeeds, such as everquest_rof2/eqgame.exe
+
+	mu        sync.Mutex
+	downloads int
+	seeds     int
+	progress  []Progress
 }
 
-func (m *Mock) Download(ctx context.Context, torrentData []byte) error {
+func (m *Mock) Download(ctx context.Context, torrentData []byte, opts Options) error {
+	m.mu.Lock()
+	m.downloads++
+	m.mu.Unlock()
+
+	for i := 1; i <= m.Steps; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.Delay):
+		}
+		p := Progress{InfoHash: "mock", Completed: m.Total * int64(i) / int64(m.Steps), Total: m.Total}
+		m.mu.Lock()
+		m.progress = append(m.progress, p)
+		m.mu.Unlock()
+		if opts.OnProgress != nil {
+			opts.OnProgress(p)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if m.Err != nil {
+		return m.Err
+	}
+
+	dataDir := opts.DataDir
+	if dataDir == "" {
+		dataDir = "."
+	}
+	for name, data := range m.Files {
+		path := filepath.Join(dataDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("mkdir: %w", err)
+		}
+		err = os.WriteFile(path, data, 0644)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Seed blocks until ctx is done or the seed duration passes
+func (m *Mock) Seed(ctx context.Context, torrentData []byte, opts SeedOptions) error {
+	m.mu.Lock()
+	m.seeds++
+	m.mu.Unlock()
+	if opts.OnProgress != nil {
+		opts.OnProgress(Progress{InfoHash: "mock", Completed: m.Total, Total: m.Total})
+	}
+
+	if opts.Duration <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(opts.Duration):
+	}
 	return nil
 }
+
+// Downloads returns how many times Download was called
+func (m *Mock) Downloads() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.downloads
+}
+
+// Seeds returns how many times Seed was called
+func (m *Mock) Seeds() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.seeds
+}
+
+// Progress returns every progress report made so far
+func (m *Mock) Progress() []Progress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Progress{}, m.progress...)
+}
@@ -0,0 +1,41 @@
+package torrent
+
+import "time"
+
+// Options controls a torrent download
+type Options struct {
+	DataDir     string           // folder the torrent's files are written to
+	PeerTimeout time.Duration    // fail if nothing is downloaded and no peers connect for this long, 0 waits forever
+	Resume      *Progress        // progress saved by an earlier run, nil if there is none
+	OnProgress  func(p Progress) // optional, called periodically and when the download ends
+	WebSeeds    []string         // extra http(s) sources (BEP 19) added to the torrent
+	Trackers    []string         // extra trackers added to the torrent
+}
+
+// Progress is how far a torrent download got, saved between launches to
+// know whether data already on disk can be trusted
+type Progress struct {
+	InfoHash  string `yaml:"info_hash"`
+	Completed int64  `yaml:"completed"`
+	Total     int64  `yaml:"total"`
+}
+
+// SeedOptions controls seeding a downloaded torrent
+type SeedOptions struct {
+	DataDir     string             // folder the torrent's files were downloaded to
+	UploadLimit int                // bytes per second, 0 is unlimited
+	Ratio       float64            // stop after uploading this many times the torrent size, 0 has no target
+	Duration    time.Duration      // stop after seeding this long, 0 seeds until ctx is done
+	Resume      *Progress          // progress saved by the download, nil if there is none
+	OnProgress  func(p Progress)   // optional, called once the data is found complete, to save as the next Resume
+	OnStatus    func(s SeedStatus) // optional, called periodically while seeding
+	Trackers    []string           // extra trackers added to the torrent
+}
+
+// SeedStatus describes a running seed
+type SeedStatus struct {
+	Peers    int
+	Uploaded int64
+	Ratio    float64
+	Elapsed  time.Duration
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/anacrolix/torrent"
@@ -11,21 +13,29 @@ import (
 	"github.com/c2h5oh/datasize"
 	"github.com/xackery/starteq/gui"
 	"github.com/xackery/starteq/slog"
+	"golang.org/x/time/rate"
 )
 
+// progressInterval is how often progress is reported and saved
+const progressInterval = 6 * time.Second
+
 type Torrent struct {
 }
 
-func (t *Torrent) Download(ctx context.Context, torrentData []byte) error {
+func (t *Torrent) Download(ctx context.Context, torrentData []byte, opts Options) error {
+	if opts.DataDir == "" {
+		opts.DataDir = "."
+	}
 
 	cfg := torrent.NewDefaultClientConfig()
-	cfg.DataDir = "."
+	cfg.DataDir = opts.DataDir
 	cfg.Debug = false
 	cfg.Seed = false
 	torrentClient, err := torrent.NewClient(cfg)
 	if err != nil {
 		return fmt.Errorf("newClient: %w", err)
 	}
+	defer torrentClient.Close()
 
 	mi, err := metainfo.Load(bytes.NewReader(torrentData))
 	if err != nil {
@@ -35,40 +45,213 @@ func (t *Torrent) Download(ctx context.Context, torrentData []byte) error {
 	if err != nil {
 		return fmt.Errorf("addTorrent: %w", err)
 	}
+	defer tr.Drop()
+	addSources(tr, opts.WebSeeds, opts.Trackers)
+
+	select {
+	case <-tr.GotInfo():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	err = t.resume(ctx, tr, opts.DataDir, opts.Resume)
+	if err != nil {
+		return err
+	}
+
+	total := tr.Info().TotalLength()
+
+	report := func() {
+		if opts.OnProgress == nil {
+			return
+		}
+		opts.OnProgress(Progress{
+			InfoHash:  tr.InfoHash().HexString(),
+			Completed: tr.BytesCompleted(),
+			Total:     total,
+		})
+	}
+	defer report()
+
+	slog.Printf("Downloading %s via Torrent", tr.Name())
+	tr.DownloadAll()
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- torrentClient.WaitAll()
+	}()
 
 	start := time.Now()
+	lastActive := start
+	lastCompleted := tr.BytesCompleted()
+	tick := time.NewTicker(progressInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ok := <-done:
+			if !ok {
+				return fmt.Errorf("torrent client closed before %s finished", tr.Name())
+			}
+			gui.SetProgress(100)
+			return nil
+		case <-tick.C:
+			st := tr.Stats()
+			completed := tr.BytesCompleted()
 
-	<-tr.GotInfo()
+			dataRate := (datasize.ByteSize(float64(st.BytesRead.Int64())/time.Since(start).Seconds()) * datasize.B)
+			remainingTime := float64(total-completed) / float64(dataRate)
+
+			totalPercent := float64(completed) / float64(total) * float64(100)
+
+			gui.SetProgress(int(totalPercent))
+			slog.Printf("peers: %d, seeders: %d, %s/s %0.2f%% of %s, ETA %0.1f minutes\n",
+				st.ActivePeers,
+				st.ConnectedSeeders,
+				dataRate.HR(),
+				totalPercent,
+				(datasize.ByteSize(total) * datasize.B).HR(),
+				remainingTime/60)
+			report()
+
+			if st.ActivePeers > 0 || completed != lastCompleted {
+				lastActive = time.Now()
+				lastCompleted = completed
+			}
+			if opts.PeerTimeout > 0 && time.Since(lastActive) > opts.PeerTimeout {
+				return fmt.Errorf("no peers found for %s within %s, check your firewall or try again later", tr.Name(), opts.PeerTimeout)
+			}
+		}
+	}
+}
+
+// Seed shares a completely downloaded torrent with other players until ctx is
+// done or the ratio or duration target is reached
+func (t *Torrent) Seed(ctx context.Context, torrentData []byte, opts SeedOptions) error {
+	if opts.DataDir == "" {
+		opts.DataDir = "."
+	}
+
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = opts.DataDir
+	cfg.Debug = false
+	cfg.Seed = true
+	if opts.UploadLimit > 0 {
+		cfg.UploadRateLimiter = rate.NewLimiter(rate.Limit(opts.UploadLimit), 256<<10)
+	}
+	torrentClient, err := torrent.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("newClient: %w", err)
+	}
+	defer torrentClient.Close()
 
+	mi, err := metainfo.Load(bytes.NewReader(torrentData))
+	if err != nil {
+		return fmt.Errorf("metainfo load: %w", err)
+	}
+	tr, err := torrentClient.AddTorrent(mi)
+	if err != nil {
+		return fmt.Errorf("addTorrent: %w", err)
+	}
 	defer tr.Drop()
-	go func() {
-		tick := time.NewTicker(6 * time.Second)
-
-		for {
-			select {
-			case <-tick.C:
-				st := tr.Stats()
-
-				dataRate := (datasize.ByteSize(float64(st.BytesRead.Int64())/time.Since(start).Seconds()) * datasize.B)
-				remainingTime := float64(tr.Info().TotalLength()) / float64(dataRate)
-
-				totalPercent := float64(tr.BytesCompleted()) / float64(tr.Info().TotalLength()) * float64(100)
-
-				gui.SetProgress(int(totalPercent))
-				slog.Printf("peers: %d, seeders: %d, %s/s %0.2f%% of %s, ETA %0.1f minutes\n",
-					st.ActivePeers,
-					st.ConnectedSeeders,
-					dataRate.HR(),
-					totalPercent,
-					(datasize.ByteSize(tr.Info().TotalLength()) * datasize.B).HR(),
-					remainingTime/60)
-			case <-tr.Closed():
-				return
+	addSources(tr, nil, opts.Trackers)
+
+	select {
+	case <-tr.GotInfo():
+	case <-ctx.Done():
+		return nil
+	}
+	tr.DisallowDataDownload()
+
+	err = t.resume(ctx, tr, opts.DataDir, opts.Resume)
+	if err != nil {
+		return nil
+	}
+	total := tr.Info().TotalLength()
+	if tr.BytesCompleted() < total {
+		return fmt.Errorf("%s is not fully downloaded, not seeding", tr.Name())
+	}
+	if opts.OnProgress != nil {
+		opts.OnProgress(Progress{
+			InfoHash:  tr.InfoHash().HexString(),
+			Completed: total,
+			Total:     total,
+		})
+	}
+
+	slog.Printf("Seeding %s", tr.Name())
+	start := time.Now()
+	tick := time.NewTicker(progressInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Printf("Stopped seeding %s", tr.Name())
+			return nil
+		case <-tick.C:
+			st := tr.Stats()
+			uploaded := st.BytesWrittenData.Int64()
+			status := SeedStatus{
+				Peers:    st.ActivePeers,
+				Uploaded: uploaded,
+				Ratio:    float64(uploaded) / float64(total),
+				Elapsed:  time.Since(start),
+			}
+			if opts.OnStatus != nil {
+				opts.OnStatus(status)
+			}
+			if opts.Ratio > 0 && status.Ratio >= opts.Ratio {
+				slog.Printf("Finished seeding %s, reached ratio %0.2f", tr.Name(), status.Ratio)
+				return nil
+			}
+			if opts.Duration > 0 && status.Elapsed >= opts.Duration {
+				slog.Printf("Finished seeding %s after %s", tr.Name(), opts.Duration)
+				return nil
 			}
 		}
+	}
+}
+
+// addSources adds web seeds and trackers beyond the ones in the .torrent file
+func addSources(tr *torrent.Torrent, webSeeds []string, trackers []string) {
+	if len(webSeeds) > 0 {
+		slog.Debug("Adding web seeds", "urls", webSeeds)
+		tr.AddWebSeeds(webSeeds)
+	}
+	if len(trackers) > 0 {
+		slog.Debug("Adding trackers", "urls", trackers)
+		tiers := [][]string{}
+		for _, tracker := range trackers {
+			tiers = append(tiers, []string{tracker})
+		}
+		tr.AddTrackers(tiers)
+	}
+}
+
+// resume verifies data already on disk when there is no saved progress that
+// matches it, so a partial download continues instead of starting over
+func (t *Torrent) resume(ctx context.Context, tr *torrent.Torrent, dataDir string, progress *Progress) error {
+	_, err := os.Stat(filepath.Join(dataDir, tr.Name()))
+	if err != nil {
+		return nil
+	}
+	if progress != nil && progress.InfoHash == tr.InfoHash().HexString() && tr.BytesCompleted() >= progress.Completed {
+		slog.Printf("Resuming %s at %s", tr.Name(), (datasize.ByteSize(tr.BytesCompleted()) * datasize.B).HR())
+		return nil
+	}
+
+	slog.Printf("Verifying existing %s data, this may take a while...", tr.Name())
+	verified := make(chan struct{})
+	go func() {
+		tr.VerifyData()
+		close(verified)
 	}()
-	slog.Printf("Downloading %s via Torrent", tr.Name())
-	tr.DownloadAll()
-	torrentClient.WaitAll()
+	select {
+	case <-verified:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	slog.Printf("%s of existing data is valid", (datasize.ByteSize(tr.BytesCompleted()) * datasize.B).HR())
 	return nil
 }
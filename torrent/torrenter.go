@@ -2,6 +2,13 @@ package torrent
 
 import "context"
 
+// Torrenter downloads the data described by a .torrent file
 type Torrenter interface {
-	Download(ctx context.Context, torrentData []byte) error
+	Download(ctx context.Context, torrentData []byte, opts Options) error
+	Seed(ctx context.Context, torrentData []byte, opts SeedOptions) error
 }
+
+var (
+	_ Torrenter = &Torrent{}
+	_ Torrenter = &Mock{}
+)